@@ -8,10 +8,11 @@ import (
 )
 
 var editCmd = &cobra.Command{
-	Use:   "edit <hostname>",
-	Short: "Edit an existing SSH host configuration",
-	Long:  `Edit an existing SSH host configuration with an interactive form.`,
-	Args:  cobra.ExactArgs(1),
+	Use:               "edit <hostname>",
+	Short:             "Edit an existing SSH host configuration",
+	Long:              `Edit an existing SSH host configuration with an interactive form.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeHosts,
 	Run: func(cmd *cobra.Command, args []string) {
 		hostname := args[0]
 
@@ -1,9 +1,11 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/exec"
 
 	"sshm/internal/config"
 	"sshm/internal/ui"
@@ -14,13 +16,20 @@ import (
 // version will be set at build time via -ldflags
 var version = "dev"
 
+// direct makes the connection bypass the host's ProxyJump or ProxyCommand
+var direct bool
+
+// profile selects the environment of the host to connect to
+var profile string
+
 var rootCmd = &cobra.Command{
 	Use:   "sshm",
 	Short: "SSH Manager - A modern SSH connection manager",
 	Long: `SSH Manager (sshm) is a modern command-line tool for managing SSH connections.
 It provides an interactive interface to browse and connect to your SSH hosts
 configured in your ~/.ssh/config file.`,
-	Version: version,
+	Version:           version,
+	ValidArgsFunction: completeHosts,
 	Run: func(cmd *cobra.Command, args []string) {
 		// If no arguments provided, run interactive mode
 		if len(args) == 0 {
@@ -34,6 +43,18 @@ configured in your ~/.ssh/config file.`,
 	},
 }
 
+// completeHosts completes the first argument with the names of the hosts
+func completeHosts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	data, err := config.GenerateCompletionData()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return data.Hosts, cobra.ShellCompDirectiveNoFileComp
+}
+
 func runInteractiveMode() {
 	// Parse SSH configurations
 	hosts, err := config.ParseSSHConfig()
@@ -77,9 +98,27 @@ func connectToHost(hostName string) {
 
 	// Connect to the host
 	fmt.Printf("Connecting to %s...\n", hostName)
-	// Note: In a real implementation, you'd use exec.Command here
-	// For now, just print the command that would be executed
-	fmt.Printf("ssh %s\n", hostName)
+	connect := config.Connect
+	if direct {
+		connect = config.ConnectDirect
+	}
+	if profile != "" {
+		connect = func(hostName string) error { return config.ConnectProfile(hostName, profile) }
+	}
+	if err := connect(hostName); err != nil {
+		// Propagate the exit status of the remote session
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		log.Fatalf("Error connecting to %s: %v", hostName, err)
+	}
+}
+
+func init() {
+	rootCmd.Flags().BoolVar(&direct, "direct", false, "Connect directly, bypassing the host's ProxyJump or ProxyCommand")
+	rootCmd.Flags().StringVar(&profile, "profile", "", "Connect to the HostName of the given profile of the host (see # Profiles:)")
+	rootCmd.PersistentFlags().StringVarP(&config.ConfigPath, "config", "F", "", "SSH config file to use instead of $GOSSHM_CONFIG or ~/.ssh/config")
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
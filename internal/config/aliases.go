@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// aliasName matches host names usable as a shell function name
+var aliasName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+// shellSafe matches words that need no quoting in any supported shell
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// quotePOSIX quotes s for bash and zsh
+func quotePOSIX(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// quoteFish quotes s for fish, where backslashes and single quotes are escaped
+// inside single quotes
+func quoteFish(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// standaloneSSHArgs returns the arguments of ssh to connect to host without
+// relying on the config: every directive is passed on the command line
+func standaloneSSHArgs(host SSHHost) []string {
+	var args []string
+	destination := host.Hostname
+	for _, d := range hostDirectives(host) {
+		switch d.Key {
+		case "HostName":
+		case "User":
+			destination = d.Value + "@" + destination
+		case "Port":
+			args = append(args, "-p", d.Value)
+		default:
+			args = append(args, "-o", d.Key+"="+d.Value)
+		}
+	}
+	for _, opt := range host.Options {
+		args = append(args, "-o", opt)
+	}
+	return append(args, destination)
+}
+
+// ExportShellAliases writes a shell function per concrete host that connects to
+// it with its resolved settings, so that hosts stay reachable where the SSH
+// config isn't available. shell is one of bash, zsh or fish. Host names that
+// can't be used as function names are skipped.
+func ExportShellAliases(w io.Writer, shell string) error {
+	var quote func(string) string
+	var format string
+	switch shell {
+	case "bash", "zsh":
+		quote = quotePOSIX
+		format = "%s() { %s \"$@\"; }\n"
+	case "fish":
+		quote = quoteFish
+		format = "function %s; %s $argv; end\n"
+	default:
+		return fmt.Errorf("unsupported shell '%s': expected bash, zsh or fish", shell)
+	}
+
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "# SSH hosts exported by gosshm for %s\n", shell); err != nil {
+		return err
+	}
+	for _, host := range hosts {
+		if IsPattern(host.Name) || !aliasName.MatchString(host.Name) {
+			continue
+		}
+		resolved := resolveHost(hosts, host.Name)
+
+		var command string
+		if resolved.Launcher != "" {
+			command = "sh -c " + quote(expandLauncher(resolved.Launcher, resolved))
+		} else {
+			words := []string{"ssh"}
+			for _, arg := range standaloneSSHArgs(resolved) {
+				words = append(words, quote(arg))
+			}
+			command = strings.Join(words, " ")
+		}
+
+		if _, err := fmt.Fprintf(w, format, host.Name, command); err != nil {
+			return err
+		}
+	}
+	return nil
+}
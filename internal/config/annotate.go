@@ -0,0 +1,46 @@
+package config
+
+import "strings"
+
+// directiveExplanations holds a short explanation of each directive gosshm
+// writes, keyed by lowercased keyword
+var directiveExplanations = map[string]string{
+	"hostname":              "Real host name or IP address to connect to",
+	"user":                  "User to log in as",
+	"port":                  "TCP port of the SSH server",
+	"identityfile":          "Private key used to authenticate",
+	"proxyjump":             "Host(s) to connect through first",
+	"proxycommand":          "Command whose input and output carry the connection",
+	"controlmaster":         "Share one connection between sessions",
+	"controlpath":           "Socket used to share the connection",
+	"ciphers":               "Allowed encryption algorithms",
+	"macs":                  "Allowed message authentication algorithms",
+	"kexalgorithms":         "Allowed key exchange algorithms",
+	"dynamicforward":        "Local port of a SOCKS proxy through the host",
+	"forwardx11":            "Forward X11 connections to the local display",
+	"forwardx11trusted":     "Give forwarded X11 clients full access to the display",
+	"forwardagent":          "Make the local SSH agent available on the host",
+	"localforward":          "Local port forwarded to an address seen from the host",
+	"remoteforward":         "Port of the host forwarded to a local address",
+	"serveraliveinterval":   "Seconds of silence before checking the server is alive",
+	"stricthostkeychecking": "How unknown or changed host keys are handled",
+	"connecttimeout":        "Seconds to wait for the connection to be established",
+}
+
+// RenderHostAnnotated renders the config block of host with a comment above
+// each directive explaining what it does, for exports meant to be read by
+// people learning the config. Comments sit on their own lines, since ssh does
+// not allow them after a value, so the output parses like the plain block.
+func RenderHostAnnotated(host SSHHost) string {
+	var lines []string
+	for _, line := range formatHostBlock(host) {
+		if strings.HasPrefix(line, " ") {
+			indent := leadingIndent(line)
+			if explanation, ok := directiveExplanations[lineKeyword(line)]; ok {
+				lines = append(lines, indent+"# "+explanation)
+			}
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
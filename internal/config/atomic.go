@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"path/filepath"
+)
+
+// atomicWriteFile replaces the content of path by writing a temporary file in
+// the same directory and renaming it over path, so that a crash leaves either
+// the old or the new content, never a truncated file. The mode and, where
+// possible, the ownership of an existing file are kept; perm is used for a new
+// one. A symlinked path is written through to its target.
+func atomicWriteFile(path string, content []byte, perm fs.FileMode) error {
+	return atomicWrite(path, perm, func(w io.Writer) error {
+		_, err := w.Write(content)
+		return err
+	})
+}
+
+// atomicWrite is atomicWriteFile with the content produced by write. When write
+// fails, path is left untouched.
+func atomicWrite(path string, perm fs.FileMode, write func(io.Writer) error) error {
+	if resolver, ok := Files.(interface{ EvalSymlinks(string) (string, error) }); ok {
+		if target, err := resolver.EvalSymlinks(path); err == nil {
+			path = target
+		}
+	}
+
+	info, statErr := Files.Stat(path)
+	if statErr == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmpPath := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.tmp-%d", filepath.Base(path), rand.Uint64()))
+	tmp, err := Files.Create(tmpPath, perm)
+	if err != nil {
+		return err
+	}
+	defer Files.Remove(tmpPath) // No-op once renamed
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	// Files of the OS are flushed to disk and given the mode of the file they
+	// replace, which the umask may have narrowed on creation
+	if file, ok := tmp.(interface{ Sync() error }); ok {
+		if err := file.Sync(); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if file, ok := tmp.(interface{ Chmod(fs.FileMode) error }); ok {
+		if err := file.Chmod(perm); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if statErr == nil {
+		keepOwner(tmp, info)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return Files.Rename(tmpPath, path)
+}
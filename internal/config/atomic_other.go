@@ -0,0 +1,11 @@
+//go:build !unix
+
+package config
+
+import (
+	"io"
+	"io/fs"
+)
+
+// keepOwner does nothing on platforms without Unix file ownership
+func keepOwner(file io.Writer, info fs.FileInfo) {}
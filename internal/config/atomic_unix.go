@@ -0,0 +1,19 @@
+//go:build unix
+
+package config
+
+import (
+	"io"
+	"io/fs"
+	"syscall"
+)
+
+// keepOwner gives file, when it is a file of the OS, the owner and group of
+// the file described by info. It is best effort: only root may give a file
+// away.
+func keepOwner(file io.Writer, info fs.FileInfo) {
+	chown, ok := file.(interface{ Chown(uid, gid int) error })
+	if stat, statOK := info.Sys().(*syscall.Stat_t); ok && statOK {
+		_ = chown.Chown(int(stat.Uid), int(stat.Gid))
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"net"
+	"sort"
+	"strings"
+)
+
+// FieldDifference is a setting that differs between hosts of the same server,
+// with the value each host resolves it to (empty when unset)
+type FieldDifference struct {
+	Field  string
+	Values map[string]string
+}
+
+// InconsistencyGroup is a set of hosts pointing at the same server that don't
+// agree on some settings
+type InconsistencyGroup struct {
+	Address     string // HostName:Port shared by the hosts
+	Hosts       []string
+	Differences []FieldDifference
+}
+
+// findInconsistentDuplicates groups the concrete hosts by resolved address and
+// reports the settings that differ within each group
+func findInconsistentDuplicates(hosts []SSHHost) []InconsistencyGroup {
+	byAddress := make(map[string][]SSHHost)
+	var addresses []string
+	for _, host := range hosts {
+		if IsPattern(host.Name) {
+			continue
+		}
+		resolved := resolveHost(hosts, host.Name)
+		address := net.JoinHostPort(resolved.Hostname, resolved.Port)
+		if _, ok := byAddress[address]; !ok {
+			addresses = append(addresses, address)
+		}
+		byAddress[address] = append(byAddress[address], resolved)
+	}
+
+	var groups []InconsistencyGroup
+	for _, address := range addresses {
+		members := byAddress[address]
+		if len(members) < 2 {
+			continue
+		}
+
+		// Collect each host's value of every directive but the address itself
+		values := make(map[string]map[string]string)
+		var fields []string
+		for _, host := range members {
+			for _, d := range hostDirectives(host) {
+				if d.Key == "HostName" || d.Key == "Port" {
+					continue
+				}
+				if values[d.Key] == nil {
+					values[d.Key] = make(map[string]string)
+					fields = append(fields, d.Key)
+				}
+				if prev := values[d.Key][host.Name]; prev != "" {
+					values[d.Key][host.Name] = prev + ", " + d.Value
+				} else {
+					values[d.Key][host.Name] = d.Value
+				}
+			}
+		}
+
+		group := InconsistencyGroup{Address: address}
+		for _, host := range members {
+			group.Hosts = append(group.Hosts, host.Name)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			diff := FieldDifference{Field: field, Values: make(map[string]string)}
+			distinct := make(map[string]bool)
+			for _, host := range members {
+				value := values[field][host.Name]
+				diff.Values[host.Name] = value
+				distinct[strings.ToLower(value)] = true
+			}
+			if len(distinct) > 1 {
+				group.Differences = append(group.Differences, diff)
+			}
+		}
+
+		if len(group.Differences) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// FindInconsistentDuplicates finds hosts that point at the same HostName:Port
+// but disagree on other settings, such as one alias logging in as root and
+// another as admin on the same server
+func FindInconsistentDuplicates() ([]InconsistencyGroup, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+	return findInconsistentDuplicates(hosts), nil
+}
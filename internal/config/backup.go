@@ -0,0 +1,285 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// legacyBackupSuffix names the single backup file kept by older versions
+const legacyBackupSuffix = ".backup"
+
+// backupTimeFormat is the layout of the timestamp in timestamped backup names
+// (config.backup.<timestamp>): RFC 3339 with fixed-width nanoseconds, so that
+// names sort by date and backups taken within the same second don't collide
+const backupTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// BackupRetention is the number of timestamped backups kept; older ones are
+// pruned whenever a backup is taken. Zero or less keeps every backup.
+var BackupRetention = 5
+
+// timestampedBackupPath returns the path of the backup of configPath taken at t
+func timestampedBackupPath(configPath string, t time.Time) string {
+	return configPath + legacyBackupSuffix + "." + t.UTC().Format(backupTimeFormat)
+}
+
+// MigrateBackups renames the single config.backup file left by older versions
+// to a timestamped name based on its modification time, so that it is kept
+// alongside the timestamped backups instead of being overwritten
+func MigrateBackups() error {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+	return migrateLegacyBackup(configPath)
+}
+
+// migrateLegacyBackup renames the legacy backup of configPath, if any
+func migrateLegacyBackup(configPath string) error {
+	legacyPath := configPath + legacyBackupSuffix
+	info, err := Files.Stat(legacyPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	target := timestampedBackupPath(configPath, info.ModTime())
+	if _, err := Files.Stat(target); err == nil {
+		// A backup with the same timestamp already exists, keep it
+		return nil
+	}
+	return Files.Rename(legacyPath, target)
+}
+
+// listBackups returns the backups of configPath, the legacy single backup
+// included, sorted by name (and therefore by date for timestamped backups)
+func listBackups(configPath string) ([]string, error) {
+	backups, err := Files.Glob(configPath + legacyBackupSuffix + "*")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// pruneBackups removes the oldest timestamped backups of configPath beyond
+// BackupRetention
+func pruneBackups(configPath string) error {
+	if BackupRetention <= 0 {
+		return nil
+	}
+	backups, err := listBackups(configPath)
+	if err != nil {
+		return err
+	}
+	for len(backups) > BackupRetention {
+		if err := Files.Remove(backups[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// ListBackups returns the timestamps of the backups of the config, most recent
+// first, as accepted by RestoreBackup
+func ListBackups() ([]string, error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	backups, err := listBackups(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := configPath + legacyBackupSuffix + "."
+	var timestamps []string
+	for i := len(backups) - 1; i >= 0; i-- {
+		if timestamp, ok := strings.CutPrefix(backups[i], prefix); ok {
+			timestamps = append(timestamps, timestamp)
+		}
+	}
+	return timestamps, nil
+}
+
+// RestoreBackup copies the backup taken at timestamp (as listed by ListBackups)
+// back over the config. The config is itself backed up first, so a restore
+// can be undone.
+func RestoreBackup(timestamp string) error {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+	if _, err := time.Parse(time.RFC3339Nano, timestamp); err != nil {
+		return fmt.Errorf("invalid backup timestamp '%s'", timestamp)
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+
+	content, err := readFile(configPath + legacyBackupSuffix + "." + timestamp)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no backup taken at %s", timestamp)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := Files.Stat(configPath); err == nil {
+		if err := backupConfig(configPath); err != nil {
+			return fmt.Errorf("failed to create backup: %w", err)
+		}
+	}
+	return writeConfigFile(configPath, content)
+}
+
+// backupsNewestFirst returns the backups of configPath, most recent first
+func backupsNewestFirst(configPath string) ([]string, error) {
+	backups, err := listBackups(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	modTimes := make(map[string]time.Time, len(backups))
+	for _, backup := range backups {
+		info, err := Files.Stat(backup)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[backup] = info.ModTime()
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return modTimes[backups[i]].After(modTimes[backups[j]])
+	})
+	return backups, nil
+}
+
+// oldestBackupTimes returns the time of the oldest backup of the config holding
+// each host found in the backups, a lower bound of the age of the host
+func oldestBackupTimes() (map[string]time.Time, error) {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	backups, err := backupsNewestFirst(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	times := make(map[string]time.Time)
+	for _, backup := range backups {
+		info, err := Files.Stat(backup)
+		if err != nil {
+			return nil, err
+		}
+		hosts, err := parseSSHConfigFile(backup, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range hosts {
+			times[host.Name] = info.ModTime()
+		}
+	}
+	return times, nil
+}
+
+// findHost returns the host named hostName among hosts
+func findHost(hosts []SSHHost, hostName string) (SSHHost, bool) {
+	for _, host := range hosts {
+		if host.Name == hostName {
+			return host, true
+		}
+	}
+	return SSHHost{}, false
+}
+
+// RevertHost restores the block of hostName to its state in the most recent
+// backup where it differs from the config, leaving the other hosts as they are.
+// A host absent from that backup is removed, and one absent from the config is
+// added back.
+func RevertHost(hostName string) error {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+
+	content, err := readConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+	hosts, err := parseSSHConfigFile(configPath, nil)
+	if err != nil {
+		return err
+	}
+	if err := applySidecarMetadata(hosts); err != nil {
+		return err
+	}
+	current, inConfig := findHost(hosts, hostName)
+
+	backups, err := backupsNewestFirst(configPath)
+	if err != nil {
+		return err
+	}
+
+	for _, backup := range backups {
+		backupHosts, err := parseSSHConfigFile(backup, nil)
+		if err != nil {
+			return err
+		}
+		if err := applySidecarMetadata(backupHosts); err != nil {
+			return err
+		}
+		previous, inBackup := findHost(backupHosts, hostName)
+		// The directives gosshm does not model are restored as well
+		if previous.Extra == nil {
+			previous.Extra = make(map[string][]string)
+		}
+		if inBackup == inConfig && (!inBackup || len(DiffHosts(current, previous)) == 0) {
+			continue
+		}
+
+		lines := strings.Split(string(content), "\n")
+		switch {
+		case inBackup && inConfig:
+			lines, _ = replaceHostBlock(lines, hostName, previous)
+		case inBackup:
+			if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+				lines = lines[:len(lines)-1]
+			}
+			lines = append(append(append(lines, ""), formatHostBlock(previous)...), "")
+		default:
+			lines, _ = removeHostBlock(lines, hostName)
+		}
+
+		// Create backup before modification
+		if err := backupConfig(configPath); err != nil {
+			return fmt.Errorf("failed to create backup: %w", err)
+		}
+		return writeConfigFile(configPath, []byte(strings.Join(lines, "\n")))
+	}
+
+	return fmt.Errorf("no backup holds a different version of host '%s'", hostName)
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// BaselineViolation is a directive of a host whose effective value differs from
+// the one required by a baseline
+type BaselineViolation struct {
+	Host      string
+	Directive string
+	Expected  string
+	Actual    string // Empty when the directive is not set
+}
+
+// effectiveDirectives returns the value ssh uses for each directive of hostName,
+// keyed by lowercased keyword. Repeatable directives keep their first value.
+func effectiveDirectives(hosts []SSHHost, hostName string) map[string]string {
+	values := make(map[string]string)
+	for key, v := range effectiveConfig(hosts, hostName) {
+		values[strings.ToLower(key)] = v[0]
+	}
+	return values
+}
+
+// selectHosts returns the concrete hosts matching selector: "#tag" selects the
+// hosts with that tag, anything else is a glob on host names. An empty selector
+// selects every host.
+func selectHosts(hosts []SSHHost, selector string) ([]SSHHost, error) {
+	tag, byTag := strings.CutPrefix(selector, "#")
+	if !byTag {
+		if _, err := path.Match(selector, ""); err != nil {
+			return nil, fmt.Errorf("invalid host pattern '%s': %w", selector, err)
+		}
+	}
+
+	return FilterHosts(hosts, func(host SSHHost) bool {
+		switch {
+		case IsPattern(host.Name):
+			return false
+		case selector == "":
+			return true
+		case byTag:
+			return hasTag(host, tag)
+		}
+		ok, _ := path.Match(selector, host.Name)
+		return ok
+	}), nil
+}
+
+// AuditAgainstBaseline checks the hosts matching selector ("#tag" or a glob on
+// names, all hosts when empty) against baseline, which maps directives to their
+// required value (e.g. "StrictHostKeyChecking": "yes"). Effective values are
+// used, including those set by wildcard entries. A required IdentityFile is
+// satisfied when it is one of the keys ssh tries for the host.
+func AuditAgainstBaseline(baseline map[string]string, selector string) ([]BaselineViolation, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+	selected, err := selectHosts(hosts, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	directives := make([]string, 0, len(baseline))
+	for directive := range baseline {
+		directives = append(directives, directive)
+	}
+	sort.Strings(directives)
+
+	var violations []BaselineViolation
+	for _, host := range selected {
+		values := effectiveDirectives(hosts, host.Name)
+		for _, directive := range directives {
+			expected := baseline[directive]
+			key := strings.ToLower(directive)
+
+			if key == "identityfile" {
+				identities := effectiveIdentities(hosts, host.Name)
+				found := false
+				for _, identity := range identities {
+					if normalizeIdentityPath(identity) == normalizeIdentityPath(expected) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					violations = append(violations, BaselineViolation{
+						Host: host.Name, Directive: directive, Expected: expected,
+						Actual: strings.Join(identities, ", "),
+					})
+				}
+				continue
+			}
+
+			if actual := values[key]; actual != expected {
+				violations = append(violations, BaselineViolation{
+					Host: host.Name, Directive: directive, Expected: expected, Actual: actual,
+				})
+			}
+		}
+	}
+	return violations, nil
+}
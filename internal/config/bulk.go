@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"sshm/internal/validation"
+)
+
+// expandRangeField formats a field of a range template with the host number,
+// leaving it as is when it has no placeholder
+func expandRangeField(field string, i int) (string, error) {
+	if !strings.Contains(field, "%") {
+		return field, nil
+	}
+	value := fmt.Sprintf(field, i)
+	if strings.Contains(value, "%!") {
+		return "", fmt.Errorf("invalid placeholder in '%s'", field)
+	}
+	return value, nil
+}
+
+// AddHostRange adds one host per number from start to end, named after
+// namePattern (e.g. "web%02d") and otherwise copied from template, whose
+// HostName may use the same placeholder (e.g. "10.0.0.%d"). Hosts that already
+// exist are skipped. The hosts are placed as AddSSHHostWithOptions places them
+// and all written at once, after a single backup.
+func AddHostRange(template SSHHost, namePattern string, start, end int, opts AddOptions) (added int, err error) {
+	if ReadOnly {
+		return 0, ErrReadOnly
+	}
+	if start > end {
+		return 0, fmt.Errorf("invalid range %d-%d", start, end)
+	}
+	if !strings.Contains(namePattern, "%") {
+		return 0, fmt.Errorf("name pattern '%s' has no placeholder", namePattern)
+	}
+	if template.Tags, err = normalizeTags(template.Tags); err != nil {
+		return 0, err
+	}
+
+	var hosts []SSHHost
+	for i := start; i <= end; i++ {
+		host := template
+		if host.Name, err = expandRangeField(namePattern, i); err != nil {
+			return 0, err
+		}
+		if !validation.ValidateHostName(host.Name) {
+			return 0, fmt.Errorf("invalid host name '%s'", host.Name)
+		}
+		if host.Hostname, err = expandRangeField(template.Hostname, i); err != nil {
+			return 0, err
+		}
+		hosts = append(hosts, host)
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return 0, err
+	}
+
+	existing, err := parseSSHConfig()
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	taken := make(map[string]bool, len(existing))
+	for _, host := range existing {
+		taken[host.Name] = true
+	}
+
+	var written []SSHHost
+	for _, host := range hosts {
+		if !taken[host.Name] {
+			written = append(written, host)
+		}
+	}
+	if len(written) == 0 {
+		return 0, nil
+	}
+
+	if configPath, err = newHostFilePath(configPath); err != nil {
+		return 0, err
+	}
+	content, err := readConfigFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	// Create backup before modification if file exists
+	if err == nil {
+		if err := backupConfig(configPath); err != nil {
+			return 0, fmt.Errorf("failed to create backup: %w", err)
+		}
+	}
+
+	lines := strings.Split(string(content), "\n")
+	for _, host := range written {
+		if lines, err = insertNewHost(lines, host, opts); err != nil {
+			return 0, err
+		}
+	}
+	if err := writeWithSidecarMetadata(func() error {
+		return writeConfigFile(configPath, []byte(strings.Join(lines, "\n")))
+	}, written); err != nil {
+		return 0, err
+	}
+	for i := range written {
+		if err := recordOperation(OpAdd, written[i].Name, &written[i]); err != nil {
+			return len(written), err
+		}
+	}
+	return len(written), nil
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAddHostRange(t *testing.T) {
+	template := SSHHost{Hostname: "10.0.0.%d", User: "deploy", Port: "22", Tags: []string{"web"}}
+
+	tests := []struct {
+		name   string
+		config string
+		opts   AddOptions
+		want   string
+	}{
+		{
+			name:   "appended",
+			config: "Host web01\n    HostName 10.0.0.1\n",
+			want: "Host web01\n    HostName 10.0.0.1\n" +
+				"\n# Tags: web\nHost web02\n    HostName 10.0.0.2\n    User deploy\n" +
+				"\n# Tags: web\nHost web03\n    HostName 10.0.0.3\n    User deploy\n",
+		},
+		{
+			name:   "above trailing Match",
+			config: "Host db\n    HostName db.example\n\nMatch all\n    User root\n",
+			want: "Host db\n    HostName db.example\n" +
+				"\n# Tags: web\nHost web01\n    HostName 10.0.0.1\n    User deploy\n" +
+				"\n# Tags: web\nHost web02\n    HostName 10.0.0.2\n    User deploy\n" +
+				"\n# Tags: web\nHost web03\n    HostName 10.0.0.3\n    User deploy\n" +
+				"\nMatch all\n    User root\n",
+		},
+		{
+			name:   "grouped with tag",
+			config: "# Tags: web\nHost web01\n    HostName 10.0.0.1\n\nHost db\n    HostName db.example\n",
+			opts:   AddOptions{GroupWithTag: true},
+			want: "# Tags: web\nHost web01\n    HostName 10.0.0.1\n" +
+				"\n# Tags: web\nHost web02\n    HostName 10.0.0.2\n    User deploy\n" +
+				"\n# Tags: web\nHost web03\n    HostName 10.0.0.3\n    User deploy\n" +
+				"\nHost db\n    HostName db.example\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useTestConfig(t, tt.config)
+			if _, err := AddHostRange(template, "web%02d", 1, 3, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			if got := readTestFile(t, path); got != tt.want {
+				t.Errorf("config = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddHostRangeManagedFile(t *testing.T) {
+	path := useTestConfig(t, "Host db\n    HostName db.example\n")
+	setForTest(t, &ManagedFile, "gosshm_hosts")
+
+	added, err := AddHostRange(SSHHost{Hostname: "10.0.0.%d", Port: "22"}, "web%d", 1, 2, AddOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 2 {
+		t.Errorf("AddHostRange() added %d hosts, want 2", added)
+	}
+
+	want := "\nHost web1\n    HostName 10.0.0.1\n\nHost web2\n    HostName 10.0.0.2\n"
+	if got := readTestFile(t, filepath.Join(filepath.Dir(path), "gosshm_hosts")); got != want {
+		t.Errorf("managed file = %q, want %q", got, want)
+	}
+	if got := readTestFile(t, path); got != "Include gosshm_hosts\n\nHost db\n    HostName db.example\n" {
+		t.Errorf("config = %q, want only the Include added", got)
+	}
+}
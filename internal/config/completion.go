@@ -0,0 +1,44 @@
+package config
+
+import "sort"
+
+// CompletionData holds the values shell completion offers for host, tag and
+// group arguments, each sorted and without duplicates
+type CompletionData struct {
+	Hosts  []string `json:"hosts"`
+	Tags   []string `json:"tags"`
+	Groups []string `json:"groups"`
+}
+
+// GenerateCompletionData collects the names of the concrete hosts of the config
+// and the tags and groups in use, in a single parse of the config
+func GenerateCompletionData() (CompletionData, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return CompletionData{}, err
+	}
+
+	seen := make(map[string]bool)
+	collect := func(list *[]string, kind, value string) {
+		if value != "" && !seen[kind+"\x00"+value] {
+			seen[kind+"\x00"+value] = true
+			*list = append(*list, value)
+		}
+	}
+
+	data := CompletionData{Hosts: []string{}, Tags: []string{}, Groups: []string{}}
+	for _, host := range hosts {
+		if !IsPattern(host.Name) {
+			collect(&data.Hosts, "host", host.Name)
+		}
+		for _, tag := range host.Tags {
+			collect(&data.Tags, "tag", tag)
+		}
+		collect(&data.Groups, "group", host.Group)
+	}
+
+	sort.Strings(data.Hosts)
+	sort.Strings(data.Tags)
+	sort.Strings(data.Groups)
+	return data, nil
+}
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// expandLauncher substitutes the {name}, {host}, {user} and {port} placeholders
+// of a launcher template with the settings of host, quoted for the shell so
+// that they are always passed as single words
+func expandLauncher(template string, host SSHHost) string {
+	hostname := host.Hostname
+	if hostname == "" {
+		hostname = host.Name
+	}
+
+	replacer := strings.NewReplacer(
+		"{name}", quotePOSIX(host.Name),
+		"{host}", quotePOSIX(hostname),
+		"{user}", quotePOSIX(host.User),
+		"{port}", quotePOSIX(effectivePort(host)),
+	)
+	return replacer.Replace(template)
+}
+
+// sshArgs returns the arguments of ssh to connect to host: the config file when
+// it is not the default one, the given extra arguments, then its passthrough options,
+// then the host name. ssh keeps the first value of an option, so the extra
+// arguments take precedence.
+func sshArgs(host SSHHost, extra ...string) []string {
+	var args []string
+	if path := customConfigPath(); path != "" {
+		args = append(args, "-F", path)
+	}
+	args = append(args, extra...)
+	for _, opt := range host.Options {
+		args = append(args, "-o", opt)
+	}
+	return append(args, host.Name)
+}
+
+// ConnectCommand returns the command used to connect to host. Hosts with a
+// Launcher run the expanded template through the shell; the others use ssh,
+// which reads the rest of the settings from the config itself.
+func ConnectCommand(host SSHHost) *exec.Cmd {
+	if host.Launcher != "" {
+		return exec.Command("sh", "-c", expandLauncher(host.Launcher, host))
+	}
+	return exec.Command("ssh", sshArgs(host)...)
+}
+
+// runAttached runs cmd with the current terminal attached
+func runAttached(cmd *exec.Cmd) error {
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// Connect opens an interactive connection to the named host, with the current
+// terminal attached
+func Connect(hostName string) error {
+	host, err := GetSSHHost(hostName)
+	if err != nil {
+		return err
+	}
+
+	// Usage tracking is best effort and must never prevent a connection
+	_ = RecordConnection(host.Name)
+
+	return runAttached(ConnectCommand(*host))
+}
+
+// SSHCommand returns the argv of an ssh command reaching h with its settings
+// given on the command line, so that it works whether or not h is in the config:
+// port when not 22, identity files, ProxyJump, ProxyCommand and passthrough
+// options, then user@hostname. An empty Hostname falls back to the name.
+func (h SSHHost) SSHCommand() []string {
+	argv := []string{"ssh"}
+	if port := effectivePort(h); port != "22" {
+		argv = append(argv, "-p", port)
+	}
+	for _, identity := range hostIdentities(h) {
+		argv = append(argv, "-i", identity)
+	}
+	if h.ProxyJump != "" {
+		argv = append(argv, "-J", h.ProxyJump)
+	}
+	if h.ProxyCommand != "" {
+		argv = append(argv, "-o", "ProxyCommand="+h.ProxyCommand)
+	}
+	for _, opt := range h.Options {
+		argv = append(argv, "-o", opt)
+	}
+
+	target := h.Hostname
+	if target == "" {
+		target = h.Name
+	}
+	if h.User != "" {
+		target = h.User + "@" + target
+	}
+	return append(argv, target)
+}
+
+// CommandString returns SSHCommand as a shell command line to copy and paste,
+// e.g. "ssh -p 2222 -i ~/.ssh/id_ed25519 -J bastion user@10.0.0.5". Arguments
+// are quoted only when needed, leaving a leading "~/" outside of the quotes so
+// that the shell still expands it.
+func (h SSHHost) CommandString() string {
+	argv := h.SSHCommand()
+	for i, arg := range argv {
+		if rest, ok := strings.CutPrefix(arg, "~/"); ok && rest != "" {
+			argv[i] = "~/" + quotePOSIX(rest)
+		} else {
+			argv[i] = quotePOSIX(arg)
+		}
+	}
+	return strings.Join(argv, " ")
+}
+
+// Connect runs SSHCommand with the current terminal attached, until the session
+// ends or ctx is done. Launchers and usage tracking are left to the callers.
+func (h SSHHost) Connect(ctx context.Context) error {
+	argv := h.SSHCommand()
+	return runAttached(exec.CommandContext(ctx, argv[0], argv[1:]...))
+}
+
+// ConnectProfile connects to the named host in one of its environments, using
+// the HostName its "# Profiles:" comment gives for profile instead of the one
+// of the config. The config is not modified.
+func ConnectProfile(hostName, profile string) error {
+	host, err := GetSSHHost(hostName)
+	if err != nil {
+		return err
+	}
+	address, ok := host.Profiles[profile]
+	if !ok {
+		return fmt.Errorf("host '%s' has no profile '%s'", hostName, profile)
+	}
+
+	// Usage tracking is best effort and must never prevent a connection
+	_ = RecordConnection(host.Name)
+
+	if host.Launcher != "" {
+		host.Hostname = address
+		return runAttached(ConnectCommand(*host))
+	}
+	return runAttached(exec.Command("ssh", sshArgs(*host, "-o", "HostName="+address)...))
+}
+
+// ConnectDirect connects to the named host without going through its configured
+// ProxyJump or ProxyCommand, e.g. when already on the target's network. The
+// config is not modified.
+func ConnectDirect(hostName string) error {
+	host, err := GetSSHHost(hostName)
+	if err != nil {
+		return err
+	}
+
+	// Usage tracking is best effort and must never prevent a connection
+	_ = RecordConnection(host.Name)
+
+	return runAttached(exec.Command("ssh", sshArgs(*host, "-o", "ProxyJump=none", "-o", "ProxyCommand=none")...))
+}
+
+// RunCommand runs command on the named host with ssh, without a terminal, and
+// returns its output and exit status. The host's passthrough options are used
+// and ssh reads the rest of its settings from the config; a Launcher is not.
+// A failure of ssh itself is reported by ssh as exit code 255, not as err.
+func RunCommand(hostName, command string) (stdout, stderr string, exitCode int, err error) {
+	host, err := GetSSHHost(hostName)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	var outBuf, errBuf bytes.Buffer
+	cmd := exec.Command("ssh", append(sshArgs(*host), command)...)
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return outBuf.String(), errBuf.String(), exitErr.ExitCode(), nil
+	}
+	return outBuf.String(), errBuf.String(), 0, err
+}
+
+// SetConnectOptions replaces the "Key=Value" options passed to ssh with -o when
+// connecting to the named host. An empty list removes them.
+func SetConnectOptions(hostName string, opts []string) error {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+
+	for _, opt := range opts {
+		key, _, found := strings.Cut(opt, "=")
+		if !found || strings.TrimSpace(key) == "" || strings.ContainsAny(opt, ";\n") {
+			return fmt.Errorf("invalid option '%s': expected Key=Value", opt)
+		}
+	}
+
+	host, err := GetSSHHost(hostName)
+	if err != nil {
+		return err
+	}
+	host.Options = opts
+	return UpdateSSHHost(hostName, *host)
+}
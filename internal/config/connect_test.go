@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCommandString(t *testing.T) {
+	type field struct {
+		set  func(*SSHHost)
+		flag string
+	}
+	// Every combination of the optional fields, in the order of the flags
+	fields := []field{
+		{func(h *SSHHost) { h.Port = "2222" }, "-p 2222"},
+		{func(h *SSHHost) { h.Identity = "~/.ssh/id_ed25519" }, "-i ~/.ssh/id_ed25519"},
+		{func(h *SSHHost) { h.ProxyJump = "bastion" }, "-J bastion"},
+	}
+	type test struct {
+		name string
+		host SSHHost
+		want string
+	}
+	var tests []test
+	for mask := 0; mask < 1<<len(fields); mask++ {
+		for _, user := range []string{"", "user"} {
+			host := SSHHost{Name: "web", Hostname: "10.0.0.5", Port: "22", User: user}
+			want := []string{"ssh"}
+			for i, f := range fields {
+				if mask&(1<<i) != 0 {
+					f.set(&host)
+					want = append(want, f.flag)
+				}
+			}
+			target := "10.0.0.5"
+			if user != "" {
+				target = user + "@" + target
+			}
+			want = append(want, target)
+			tests = append(tests, test{fmt.Sprintf("fields %03b user %q", mask, user), host, strings.Join(want, " ")})
+		}
+	}
+
+	tests = append(tests, []test{
+		{
+			name: "no HostName",
+			host: SSHHost{Name: "web"},
+			want: "ssh web",
+		},
+		{
+			name: "identity with spaces",
+			host: SSHHost{Name: "web", Hostname: "10.0.0.5", Identity: "~/.ssh/my key"},
+			want: "ssh -i ~/'.ssh/my key' 10.0.0.5",
+		},
+		{
+			name: "absolute identity with spaces",
+			host: SSHHost{Name: "web", Hostname: "10.0.0.5", Identity: "/keys/my key"},
+			want: "ssh -i '/keys/my key' 10.0.0.5",
+		},
+		{
+			name: "identity with a quote",
+			host: SSHHost{Name: "web", Hostname: "10.0.0.5", Identity: "/keys/bob's"},
+			want: `ssh -i '/keys/bob'\''s' 10.0.0.5`,
+		},
+		{
+			name: "several identities",
+			host: SSHHost{Name: "web", Hostname: "10.0.0.5", Identity: "~/.ssh/a", Identities: []string{"~/.ssh/a", "~/.ssh/b"}},
+			want: "ssh -i ~/.ssh/a -i ~/.ssh/b 10.0.0.5",
+		},
+		{
+			name: "empty port",
+			host: SSHHost{Name: "web", Hostname: "10.0.0.5"},
+			want: "ssh 10.0.0.5",
+		},
+		{
+			name: "proxy command and options",
+			host: SSHHost{Name: "web", Hostname: "10.0.0.5", ProxyCommand: "nc -X 5 %h %p", Options: []string{"ServerAliveInterval=30"}},
+			want: "ssh -o 'ProxyCommand=nc -X 5 %h %p' -o ServerAliveInterval=30 10.0.0.5",
+		},
+	}...)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.host.CommandString(); got != tt.want {
+				t.Errorf("CommandString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandLauncher(t *testing.T) {
+	tests := []struct {
+		name string
+		host SSHHost
+		want string
+	}{
+		{
+			name: "plain values",
+			host: SSHHost{Name: "web", Hostname: "web.example", User: "admin", Port: "2222"},
+			want: `mosh --ssh="ssh -p 2222" admin@web.example # web`,
+		},
+		{
+			name: "shell metacharacters",
+			host: SSHHost{Name: "web", Hostname: "web.example; touch /tmp/pwned", User: "$(id)"},
+			want: `mosh --ssh="ssh -p 22" '$(id)'@'web.example; touch /tmp/pwned' # web`,
+		},
+		{
+			name: "single quote",
+			host: SSHHost{Name: "it's", User: "admin"},
+			want: `mosh --ssh="ssh -p 22" admin@'it'\''s' # 'it'\''s'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandLauncher(`mosh --ssh="ssh -p {port}" {user}@{host} # {name}`, tt.host); got != tt.want {
+				t.Errorf("expandLauncher() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnectCommandLauncherQuoting(t *testing.T) {
+	host := SSHHost{Name: "web", Hostname: "web.example; echo injected", User: "a b", Launcher: "printf '%s\\n' {user} {host}"}
+
+	out, err := ConnectCommand(host).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(out), "a b\nweb.example; echo injected\n"; got != want {
+		t.Errorf("launcher output = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// usesControlMaster reports whether a ControlMaster value enables multiplexing
+func usesControlMaster(value string) bool {
+	switch strings.ToLower(value) {
+	case "yes", "auto", "ask", "autoask":
+		return true
+	}
+	return false
+}
+
+// expandControlPath resolves the % tokens and leading ~ of a ControlPath template
+// for the given (resolved) host
+func expandControlPath(template string, host SSHHost) string {
+	localUser := ""
+	uid := ""
+	if u, err := user.Current(); err == nil {
+		localUser = u.Username
+		uid = u.Uid
+	}
+	remoteUser := host.User
+	if remoteUser == "" {
+		remoteUser = localUser
+	}
+	homeDir, _ := os.UserHomeDir()
+	localHost, _ := os.Hostname()
+	shortHost, _, _ := strings.Cut(localHost, ".")
+
+	// %C is a hash of the local host name, remote host, port and user
+	sum := sha1.Sum([]byte(localHost + host.Hostname + host.Port + remoteUser))
+
+	tokens := map[byte]string{
+		'%': "%",
+		'C': hex.EncodeToString(sum[:]),
+		'd': homeDir,
+		'h': host.Hostname,
+		'i': uid,
+		'L': shortHost,
+		'l': localHost,
+		'n': host.Name,
+		'p': host.Port,
+		'r': remoteUser,
+		'u': localUser,
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(template); i++ {
+		if template[i] == '%' && i+1 < len(template) {
+			if value, ok := tokens[template[i+1]]; ok {
+				b.WriteString(value)
+				i++
+				continue
+			}
+		}
+		b.WriteByte(template[i])
+	}
+
+	expanded := b.String()
+	if strings.HasPrefix(expanded, "~/") {
+		expanded = filepath.Join(homeDir, expanded[2:])
+	}
+	return expanded
+}
+
+// controlSocketPaths returns the resolved ControlPath of every concrete host
+// that uses connection multiplexing
+func controlSocketPaths(hosts []SSHHost) []string {
+	seen := make(map[string]bool)
+	var paths []string
+
+	for _, host := range hosts {
+		if IsPattern(host.Name) {
+			continue
+		}
+		resolved := resolveHost(hosts, host.Name)
+		if !usesControlMaster(resolved.ControlMaster) || resolved.ControlPath == "" || strings.EqualFold(resolved.ControlPath, "none") {
+			continue
+		}
+
+		path := expandControlPath(resolved.ControlPath, resolved)
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+	return paths
+}
+
+// ListControlSockets returns the control sockets that currently exist for the
+// hosts using ControlMaster
+func ListControlSockets() ([]string, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var sockets []string
+	for _, path := range controlSocketPaths(hosts) {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.Mode()&os.ModeSocket != 0 {
+			sockets = append(sockets, path)
+		}
+	}
+	return sockets, nil
+}
+
+// CleanStaleControlSockets removes the control sockets whose master process is
+// gone, i.e. sockets nothing is listening on anymore
+func CleanStaleControlSockets() (removed int, err error) {
+	if ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	sockets, err := ListControlSockets()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, path := range sockets {
+		conn, dialErr := net.Dial("unix", path)
+		if dialErr == nil {
+			conn.Close()
+			continue
+		}
+		if !errors.Is(dialErr, syscall.ECONNREFUSED) {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// ControlPathCollision is a control socket path shared by hosts connecting to
+// different destinations, which ssh would multiplex over the same connection
+type ControlPathCollision struct {
+	Path  string
+	Hosts []string
+}
+
+// FindControlPathCollisions resolves the ControlPath of every concrete host
+// using multiplexing and reports the paths shared by hosts with a different
+// user, host name or port, e.g. a hardcoded path without %r, %h and %p. Hosts
+// reaching the same destination may safely share a socket and are not reported.
+func FindControlPathCollisions() ([]ControlPathCollision, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	byPath := make(map[string][]string)
+	destinations := make(map[string]map[string]bool)
+	for _, host := range hosts {
+		if IsPattern(host.Name) {
+			continue
+		}
+		resolved := resolveHost(hosts, host.Name)
+		if !usesControlMaster(resolved.ControlMaster) || resolved.ControlPath == "" || strings.EqualFold(resolved.ControlPath, "none") {
+			continue
+		}
+
+		path := expandControlPath(resolved.ControlPath, resolved)
+		if destinations[path] == nil {
+			destinations[path] = make(map[string]bool)
+		}
+		destinations[path][resolved.User+"@"+resolved.Hostname+":"+resolved.Port] = true
+		byPath[path] = append(byPath[path], host.Name)
+	}
+
+	var collisions []ControlPathCollision
+	for path, names := range byPath {
+		if len(destinations[path]) > 1 {
+			collisions = append(collisions, ControlPathCollision{Path: path, Hosts: names})
+		}
+	}
+	sort.Slice(collisions, func(i, j int) bool { return collisions[i].Path < collisions[j].Path })
+	return collisions, nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"strings"
+)
+
+// DeprecatedDirective is an obsolete directive found in the config
+type DeprecatedDirective struct {
+	File    string
+	Line    int
+	Host    string // Empty for directives outside of any Host block
+	Keyword string
+	Value   string
+	Hint    string
+}
+
+// deprecatedDirectives maps the lowercased obsolete keywords to advice on
+// what to do with them
+var deprecatedDirectives = map[string]string{
+	"protocol":                        "SSH protocol 1 support was removed, the directive can be deleted",
+	"rhostsrsaauthentication":         "SSH protocol 1 option, the directive can be deleted",
+	"rsaauthentication":               "SSH protocol 1 option, the directive can be deleted",
+	"cipher":                          "SSH protocol 1 option, use Ciphers instead",
+	"compressionlevel":                "SSH protocol 1 option, the directive can be deleted",
+	"useprivilegedport":               "no longer supported, the directive can be deleted",
+	"useroaming":                      "no longer supported, the directive can be deleted",
+	"fallbacktorsh":                   "no longer supported, the directive can be deleted",
+	"usersh":                          "no longer supported, the directive can be deleted",
+	"challengeresponseauthentication": "deprecated alias, use KbdInteractiveAuthentication instead",
+	"dsaauthentication":               "deprecated alias, use PubkeyAuthentication instead",
+}
+
+// FindDeprecatedDirectives lists the obsolete directives used in the config and
+// the files it includes, with their location
+func FindDeprecatedDirectives() ([]DeprecatedDirective, error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := collectConfigFiles(configPath, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+
+	var found []DeprecatedDirective
+	for _, file := range files {
+		content, err := readConfigFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		currentHost := ""
+		for i, line := range strings.Split(string(content), "\n") {
+			keyword, value := splitDirective(line)
+			if value == "" || strings.HasPrefix(keyword, "#") {
+				continue
+			}
+
+			key := strings.ToLower(keyword)
+			if key == "host" || key == "match" {
+				currentHost = strings.Join(configArgs(value), " ")
+				continue
+			}
+
+			if hint, ok := deprecatedDirectives[key]; ok {
+				found = append(found, DeprecatedDirective{
+					File:    file,
+					Line:    i + 1,
+					Host:    currentHost,
+					Keyword: keyword,
+					Value:   value,
+					Hint:    hint,
+				})
+			}
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindDeprecatedDirectives(t *testing.T) {
+	path := useTestConfig(t, `Protocol 2
+# UseRoaming no
+
+Host "my host"
+    HostName example.com
+    UseRoaming=no
+    cipher = blowfish
+
+Match all
+    RSAAuthentication no
+`)
+
+	got, err := FindDeprecatedDirectives()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []DeprecatedDirective
+	for _, d := range []DeprecatedDirective{
+		{Line: 1, Keyword: "Protocol", Value: "2"},
+		{Line: 6, Host: "my host", Keyword: "UseRoaming", Value: "no"},
+		{Line: 7, Host: "my host", Keyword: "cipher", Value: "blowfish"},
+		{Line: 10, Host: "all", Keyword: "RSAAuthentication", Value: "no"},
+	} {
+		d.File = path
+		d.Hint = deprecatedDirectives[strings.ToLower(d.Keyword)]
+		want = append(want, d)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindDeprecatedDirectives() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// HostChange is a setting that differs between two versions of a host, with its
+// old and new values (empty when unset)
+type HostChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// directiveValues returns the values of the directives of host by keyword,
+// repeated directives joined with commas, and the keywords in render order
+func directiveValues(host SSHHost) (map[string]string, []string) {
+	host.Port = effectivePort(host)
+	values := make(map[string]string)
+	var keys []string
+	for _, d := range hostDirectives(host) {
+		if prev, ok := values[d.Key]; ok {
+			values[d.Key] = prev + ", " + d.Value
+			continue
+		}
+		values[d.Key] = d.Value
+		keys = append(keys, d.Key)
+	}
+	return values, keys
+}
+
+// mapKeys returns the keys of a and b, sorted and without duplicates
+func mapKeys(a, b map[string]string) []string {
+	var keys []string
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// DiffHosts returns the settings that differ between old and new, metadata
+// first and then directives in the order they are written. The names of the
+// hosts are not compared, nor are the directives gosshm does not model when
+// new.Extra is nil, since writing new keeps them.
+func DiffHosts(old, new SSHHost) []HostChange {
+	var changes []HostChange
+	add := func(field, a, b string) {
+		if a != b {
+			changes = append(changes, HostChange{Field: field, Old: a, New: b})
+		}
+	}
+
+	// Nil comments leave the existing ones in place, so they are no change
+	if new.Comments != nil {
+		add("Comments", strings.Join(old.Comments, "\n"), strings.Join(new.Comments, "\n"))
+	}
+	add("Tags", strings.Join(old.Tags, ", "), strings.Join(new.Tags, ", "))
+	add("Group", old.Group, new.Group)
+
+	for _, k := range mapKeys(old.Meta, new.Meta) {
+		add("Meta."+k, old.Meta[k], new.Meta[k])
+	}
+
+	for _, k := range mapKeys(old.Profiles, new.Profiles) {
+		add("Profiles."+k, old.Profiles[k], new.Profiles[k])
+	}
+
+	add("Launcher", old.Launcher, new.Launcher)
+	add("Options", strings.Join(old.Options, "; "), strings.Join(new.Options, "; "))
+
+	if new.Extra == nil {
+		old.Extra = nil
+	}
+	oldValues, keys := directiveValues(old)
+	newValues, newKeys := directiveValues(new)
+	for _, key := range newKeys {
+		if _, ok := oldValues[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		add(key, oldValues[key], newValues[key])
+	}
+
+	return changes
+}
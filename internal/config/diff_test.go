@@ -0,0 +1,42 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffHosts(t *testing.T) {
+	base := SSHHost{Name: "web", Hostname: "web.example", User: "deploy", Port: "22"}
+	withExtra := base
+	withExtra.Extra = map[string][]string{"Compression": {"yes"}}
+
+	tests := []struct {
+		name     string
+		old, new SSHHost
+		want     []HostChange
+	}{
+		{"same", base, base, nil},
+		{
+			name: "changed user",
+			old:  base,
+			new:  SSHHost{Name: "web", Hostname: "web.example", User: "root"},
+			want: []HostChange{{Field: "User", Old: "deploy", New: "root"}},
+		},
+		{"nil extra keeps unknown directives", withExtra, base, nil},
+		{
+			name: "empty extra drops unknown directives",
+			old:  withExtra,
+			new: SSHHost{Name: "web", Hostname: "web.example", User: "deploy", Port: "22",
+				Extra: map[string][]string{}},
+			want: []HostChange{{Field: "Compression", Old: "yes", New: ""}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffHosts(tt.old, tt.new); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffHosts() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// isResolvableName reports whether a HostName value is a DNS name worth looking
+// up: IP literals and names holding % tokens are not
+func isResolvableName(hostname string) bool {
+	if hostname == "" || strings.Contains(hostname, "%") {
+		return false
+	}
+	literal := strings.Trim(hostname, "[]")
+	if i := strings.Index(literal, "%"); i >= 0 {
+		literal = literal[:i]
+	}
+	return net.ParseIP(literal) == nil
+}
+
+// FindUnresolvableHosts looks up the HostName of every concrete host and returns
+// the hosts whose name does not exist in DNS, e.g. decommissioned servers. Only
+// definitive "not found" answers count: lookups failing for other reasons are
+// not reported. Hosts reached through a ProxyJump or ProxyCommand are skipped,
+// since their name is resolved on the other end.
+func FindUnresolvableHosts(ctx context.Context) ([]SSHHost, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	unresolvable := make([]bool, len(hosts))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, pingConcurrency)
+
+	for i, host := range hosts {
+		if IsPattern(host.Name) {
+			continue
+		}
+		resolved := resolveHost(hosts, host.Name)
+		if isProxied(resolved) {
+			continue
+		}
+		if !isResolvableName(resolved.Hostname) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, hostname string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			_, err := net.DefaultResolver.LookupHost(ctx, hostname)
+			var dnsErr *net.DNSError
+			if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+				unresolvable[i] = true
+			}
+		}(i, resolved.Hostname)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var result []SSHHost
+	for i, host := range hosts {
+		if unresolvable[i] {
+			result = append(result, host)
+		}
+	}
+	return result, nil
+}
+
+// PruneUnresolvableHosts deletes the hosts FindUnresolvableHosts reports, in a
+// single backed-up write, and returns their names. Hosts defined in included
+// files are reported by FindUnresolvableHosts but left in place.
+func PruneUnresolvableHosts(ctx context.Context) (pruned []string, err error) {
+	if ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	stale, err := FindUnresolvableHosts(ctx)
+	if err != nil || len(stale) == 0 {
+		return nil, err
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := readConfigFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(string(content), "\n")
+	for _, host := range stale {
+		if host.SourceFile != configPath {
+			continue
+		}
+		var found bool
+		if lines, found = removeHostBlock(lines, host.Name); found {
+			pruned = append(pruned, host.Name)
+		}
+	}
+	if len(pruned) == 0 {
+		return nil, nil
+	}
+
+	// Create backup before modification
+	if err := backupConfig(configPath); err != nil {
+		return nil, fmt.Errorf("failed to create backup: %w", err)
+	}
+
+	if err := writeWithSidecarMetadata(func() error {
+		return writeConfigFile(configPath, []byte(strings.Join(lines, "\n")))
+	}, nil, pruned...); err != nil {
+		return nil, err
+	}
+	for _, name := range pruned {
+		if err := recordOperation(OpDelete, name, nil); err != nil {
+			return pruned, err
+		}
+	}
+	return pruned, nil
+}
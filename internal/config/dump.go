@@ -0,0 +1,149 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ModelDump is the complete view gosshm has of the config, for troubleshooting
+// and for tools that need more than the export format
+type ModelDump struct {
+	ConfigPath string
+	Files      []string
+	// Global holds, per file, the directives that appear before its first
+	// Host or Match block, keyed by keyword as written
+	Global   map[string]map[string][]string
+	Includes []IncludeDirective
+	Hosts    []SSHHost
+}
+
+// IncludeDirective is an Include line of a config file, positioned relative to
+// the hosts of that file. Writers never move Include lines, since their position
+// decides which settings take precedence.
+type IncludeDirective struct {
+	Pattern    string
+	SourceFile string
+	LineNumber int
+	After      string // Host whose block precedes the Include, empty before the first one
+}
+
+// parseIncludeDirectives returns the Include directives of a file, in order
+func parseIncludeDirectives(path string) ([]IncludeDirective, error) {
+	content, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var includes []IncludeDirective
+	after := ""
+	for i, line := range strings.Split(string(content), "\n") {
+		keyword, value := splitDirective(line)
+		if value == "" {
+			continue
+		}
+		switch strings.ToLower(keyword) {
+		case "host":
+			after = strings.Join(configArgs(value), " ")
+		case "match":
+			after = ""
+		case "include":
+			includes = append(includes, IncludeDirective{
+				Pattern:    value,
+				SourceFile: path,
+				LineNumber: i + 1,
+				After:      after,
+			})
+		}
+	}
+	return includes, nil
+}
+
+// ListIncludes returns the Include directives of the config and of the files it
+// includes, in the order the files are read
+func ListIncludes() ([]IncludeDirective, error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := collectConfigFiles(configPath, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+
+	var includes []IncludeDirective
+	for _, file := range files {
+		fileIncludes, err := parseIncludeDirectives(file)
+		if err != nil {
+			return nil, err
+		}
+		includes = append(includes, fileIncludes...)
+	}
+	return includes, nil
+}
+
+// parseGlobalDirectives returns the directives of a file that appear before
+// its first Host or Match block
+func parseGlobalDirectives(path string) (map[string][]string, error) {
+	content, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	global := make(map[string][]string)
+	for _, line := range strings.Split(string(content), "\n") {
+		keyword, value := splitDirective(line)
+		if value == "" || strings.HasPrefix(keyword, "#") {
+			continue
+		}
+		key := strings.ToLower(keyword)
+		if key == "host" || key == "match" {
+			break
+		}
+		global[keyword] = append(global[keyword], value)
+	}
+	return global, nil
+}
+
+// DumpModel returns the parsed model of the config as indented JSON: every host
+// with all of its fields and location, and the global directives of each file
+func DumpModel() ([]byte, error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := collectConfigFiles(configPath, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+
+	dump := ModelDump{
+		ConfigPath: configPath,
+		Files:      files,
+		Global:     make(map[string]map[string][]string),
+		Hosts:      hosts,
+	}
+	for _, file := range files {
+		global, err := parseGlobalDirectives(file)
+		if err != nil {
+			return nil, err
+		}
+		if len(global) > 0 {
+			dump.Global[file] = global
+		}
+
+		includes, err := parseIncludeDirectives(file)
+		if err != nil {
+			return nil, err
+		}
+		dump.Includes = append(dump.Includes, includes...)
+	}
+
+	return json.MarshalIndent(dump, "", "  ")
+}
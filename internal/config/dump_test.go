@@ -0,0 +1,146 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseIncludeDirectives(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []IncludeDirective
+	}{
+		{
+			name:    "before and after hosts",
+			content: "Include first.conf\n\nHost web\n    HostName web.example\n\nInclude second.conf\n",
+			want: []IncludeDirective{
+				{Pattern: "first.conf", LineNumber: 1},
+				{Pattern: "second.conf", LineNumber: 6, After: "web"},
+			},
+		},
+		{
+			name:    "key=value",
+			content: "Host web\n\nInclude=conf.d/*\n",
+			want:    []IncludeDirective{{Pattern: "conf.d/*", LineNumber: 3, After: "web"}},
+		},
+		{
+			name:    "quoted",
+			content: "Host \"my host\"\ninclude \"my dir/*.conf\" other.conf\n",
+			want:    []IncludeDirective{{Pattern: `"my dir/*.conf" other.conf`, LineNumber: 2, After: "my host"}},
+		},
+		{
+			name:    "after match",
+			content: "Host web\nMatch all\nInclude extra.conf\n",
+			want:    []IncludeDirective{{Pattern: "extra.conf", LineNumber: 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			for i := range tt.want {
+				tt.want[i].SourceFile = path
+			}
+
+			got, err := parseIncludeDirectives(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseIncludeDirectives() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGlobalDirectives(t *testing.T) {
+	const content = `# Defaults
+ServerAliveInterval 60
+AddKeysToAgent=yes
+SendEnv LANG LC_*
+SendEnv = TERM
+ProxyCommand "ssh -W %h:%p bastion"
+
+Host web
+    User deploy
+`
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := parseGlobalDirectives(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"ServerAliveInterval": {"60"},
+		"AddKeysToAgent":      {"yes"},
+		"SendEnv":             {"LANG LC_*", "TERM"},
+		"ProxyCommand":        {`"ssh -W %h:%p bastion"`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGlobalDirectives() = %q, want %q", got, want)
+	}
+}
+
+const includeBetweenHostsConfig = `Include first.conf
+
+# Tags: web
+Host web
+    HostName web.example
+
+Include extra.conf
+
+Host db
+    HostName db.example
+`
+
+func TestIncludePositionKept(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func() error
+	}{
+		{"add", func() error { return AddSSHHost(SSHHost{Name: "cache", Hostname: "cache.example"}) }},
+		{"add with tag", func() error {
+			return AddSSHHostWithOptions(SSHHost{Name: "cache", Hostname: "cache.example", Tags: []string{"web"}}, AddOptions{GroupWithTag: true})
+		}},
+		{"update before", func() error { return UpdateSSHHost("web", SSHHost{Name: "web", Hostname: "web2.example"}) }},
+		{"update after", func() error { return UpdateSSHHost("db", SSHHost{Name: "db", Hostname: "db2.example"}) }},
+		{"rename", func() error { return RenameSSHHost("db", "database") }},
+		{"tidy", func() error { _, err := TidyConfig(); return err }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useTestConfig(t, includeBetweenHostsConfig)
+			dir := filepath.Dir(path)
+			for _, name := range []string{"first.conf", "extra.conf"} {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte("Host "+name+"\n"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tt.write(); err != nil {
+				t.Fatal(err)
+			}
+
+			includes, err := ListIncludes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, include := range includes {
+				got = append(got, include.After+" < "+include.Pattern)
+			}
+			if want := []string{" < first.conf", "web < extra.conf"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("includes = %q, want %q\nconfig:\n%s", got, want, readTestFile(t, path))
+			}
+		})
+	}
+}
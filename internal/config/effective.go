@@ -0,0 +1,62 @@
+package config
+
+import "strings"
+
+// repeatableDirectives holds the lowercased keywords whose values accumulate
+// over all the matching entries instead of the first one winning
+var repeatableDirectives = map[string]bool{
+	"identityfile":    true,
+	"certificatefile": true,
+	"dynamicforward":  true,
+	"localforward":    true,
+	"remoteforward":   true,
+	"sendenv":         true,
+}
+
+// effectiveConfig returns the directives ssh applies to hostName over all the
+// matching entries, keyed by keyword as written by gosshm (HostName, User...)
+// or, for directives it does not model, as first written in the config
+func effectiveConfig(hosts []SSHHost, hostName string) map[string][]string {
+	config := make(map[string][]string)
+	keys := make(map[string]string) // Lowercased keyword to the key used in config
+	for _, host := range hosts {
+		if host.Name != hostName && !matchesHost(host.Name, hostName) {
+			continue
+		}
+		for _, d := range hostDirectives(host) {
+			lower := strings.ToLower(d.Key)
+			key, ok := keys[lower]
+			if !ok {
+				key = d.Key
+				keys[lower] = key
+			} else if !repeatableDirectives[lower] {
+				continue
+			}
+			config[key] = append(config[key], d.Value)
+		}
+	}
+
+	if identities := effectiveIdentities(hosts, hostName); len(identities) > 0 {
+		config[keys["identityfile"]] = identities
+	}
+	if _, ok := keys["hostname"]; !ok {
+		config["HostName"] = []string{hostName}
+	}
+	if _, ok := keys["port"]; !ok {
+		config["Port"] = []string{"22"}
+	}
+	return config
+}
+
+// EffectiveConfigMap returns the settings ssh uses to connect to target, in the
+// spirit of "ssh -G": each directive set by the entries matching target,
+// including wildcard ones, maps to its value, or to all of its values for
+// repeatable directives such as IdentityFile. HostName and Port are always
+// present.
+func EffectiveConfigMap(target string) (map[string][]string, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+	return effectiveConfig(hosts, target), nil
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExportHostWithDeps renders a standalone config holding hostName and every host
+// of its ProxyJump chain, followed transitively, so that it can be handed to
+// someone who needs to reach that one server. Each host is rendered with its
+// resolved settings, including those inherited from wildcard entries, and
+// without gosshm metadata. Hops that are not defined in the config are left as
+// written in ProxyJump.
+func ExportHostWithDeps(hostName string) (string, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return "", err
+	}
+	if _, ok := findHost(hosts, hostName); !ok {
+		return "", fmt.Errorf("host '%s' not found", hostName)
+	}
+
+	var blocks []string
+	visited := make(map[string]bool)
+	queue := []string{hostName}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if visited[name] {
+			continue
+		}
+		visited[name] = true
+
+		resolved := resolveHost(hosts, name)
+		standalone := resolved
+		standalone.Tags, standalone.Group, standalone.Meta, standalone.Profiles = nil, "", nil, nil
+		standalone.Launcher, standalone.Options = "", nil
+		blocks = append(blocks, strings.Join(formatHostBlock(standalone), "\n"))
+
+		for _, hop := range jumpHosts(resolved.ProxyJump) {
+			if isDefinedHost(hosts, hop) {
+				queue = append(queue, hop)
+			}
+		}
+	}
+
+	return strings.Join(blocks, "\n\n") + "\n", nil
+}
+
+// KeyMapping ties a host to the keys used to log in to it, for credential
+// managers and SSH agents
+type KeyMapping struct {
+	Host          string   `json:"host"`
+	HostName      string   `json:"hostname"`
+	User          string   `json:"user,omitempty"`
+	Port          string   `json:"port"`
+	IdentityFiles []string `json:"identity_files"`
+}
+
+// ExportKeyMapping returns, for each concrete host, its resolved address and
+// user and the identity files ssh uses for it, with ~ and % tokens expanded
+func ExportKeyMapping() ([]KeyMapping, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var mappings []KeyMapping
+	for _, host := range hosts {
+		if IsPattern(host.Name) {
+			continue
+		}
+		resolved := resolveHost(hosts, host.Name)
+		mapping := KeyMapping{
+			Host:          host.Name,
+			HostName:      resolved.Hostname,
+			User:          resolved.User,
+			Port:          resolved.Port,
+			IdentityFiles: []string{},
+		}
+		for _, identity := range effectiveIdentities(hosts, host.Name) {
+			mapping.IdentityFiles = append(mapping.IdentityFiles, expandControlPath(strings.Trim(identity, "\""), resolved))
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, nil
+}
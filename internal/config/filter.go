@@ -0,0 +1,63 @@
+package config
+
+import "sort"
+
+// FilterHosts returns the hosts for which pred is true, in order
+func FilterHosts(hosts []SSHHost, pred func(SSHHost) bool) []SSHHost {
+	var filtered []SSHHost
+	for _, host := range hosts {
+		if pred(host) {
+			filtered = append(filtered, host)
+		}
+	}
+	return filtered
+}
+
+// hasTag reports whether host carries tag
+func hasTag(host SSHHost, tag string) bool {
+	for _, t := range host.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterByTag returns the hosts carrying every one of tags
+func FilterByTag(hosts []SSHHost, tags ...string) []SSHHost {
+	return FilterHosts(hosts, func(host SSHHost) bool {
+		for _, tag := range tags {
+			if !hasTag(host, tag) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// AllTags returns the tags used by hosts, each once, sorted
+func AllTags(hosts []SSHHost) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, host := range hosts {
+		for _, tag := range host.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// FilterByUser returns the hosts logging in as user
+func FilterByUser(hosts []SSHHost, user string) []SSHHost {
+	return FilterHosts(hosts, func(host SSHHost) bool { return host.User == user })
+}
+
+// FilterByPort returns the hosts connecting to port, 22 matching the hosts
+// that don't set one
+func FilterByPort(hosts []SSHHost, port string) []SSHHost {
+	return FilterHosts(hosts, func(host SSHHost) bool { return effectivePort(host) == port })
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+var taggedHosts = []SSHHost{
+	{Name: "web1", Tags: []string{"web", "production"}},
+	{Name: "web2", Tags: []string{"web", "staging"}},
+	{Name: "db1", Tags: []string{"db", "production"}},
+	{Name: "db2", Tags: []string{"production", "db", "backup"}},
+	{Name: "scratch"},
+}
+
+func TestFilterByTag(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []string
+		want []string
+	}{
+		{"one tag", []string{"production"}, []string{"web1", "db1", "db2"}},
+		{"overlapping tags", []string{"production", "db"}, []string{"db1", "db2"}},
+		{"order of tags", []string{"db", "production"}, []string{"db1", "db2"}},
+		{"three tags", []string{"db", "production", "backup"}, []string{"db2"}},
+		{"no host with all", []string{"web", "db"}, nil},
+		{"unknown tag", []string{"qa"}, nil},
+		{"case sensitive", []string{"Production"}, nil},
+		{"no tag", nil, []string{"web1", "web2", "db1", "db2", "scratch"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, host := range FilterByTag(taggedHosts, tt.tags...) {
+				got = append(got, host.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FilterByTag(%q) = %q, want %q", tt.tags, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		hosts []SSHHost
+		want  []string
+	}{
+		{"overlapping tags", taggedHosts, []string{"backup", "db", "production", "staging", "web"}},
+		{"no tags", []SSHHost{{Name: "scratch"}}, nil},
+		{"no hosts", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AllTags(tt.hosts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AllTags() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// flattenFile returns the lines of configPath with each Include directive
+// replaced by the (flattened) content of the files it matches
+func flattenFile(configPath string, visited map[string]bool) ([]string, error) {
+	absPath, first, err := enterConfigFile(visited, configPath)
+	if err != nil || !first {
+		return nil, err
+	}
+	defer func() { visited[absPath] = false }()
+
+	content, err := readConfigFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(string(content), "\n"), "\n") {
+		keyword, value := splitDirective(line)
+		if value == "" || strings.ToLower(keyword) != "include" {
+			lines = append(lines, line)
+			continue
+		}
+
+		paths, err := resolveIncludePaths(configPath, value)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			included, err := flattenFile(path, visited)
+			if err != nil {
+				return nil, extendIncludeCycle(err, absPath)
+			}
+			lines = append(lines, included...)
+		}
+	}
+	return lines, nil
+}
+
+// FlattenConfig returns the config with the content of every included file
+// inlined in place of its Include directive, comments and global directives
+// included. The config itself is left untouched.
+func FlattenConfig() (string, error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return "", err
+	}
+
+	lines, err := flattenFile(configPath, make(map[string]bool))
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// WriteFlattenedConfig replaces the config with its flattened version (see
+// FlattenConfig). The included files are left in place but no longer used.
+func WriteFlattenedConfig() error {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	flattened, err := FlattenConfig()
+	if err != nil {
+		return err
+	}
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+
+	// Create backup before modification
+	if err := backupConfig(configPath); err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+
+	return writeConfigFile(configPath, []byte(flattened))
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FileSystem is what the package reads and writes its files through: the SSH
+// config and the files it includes, backups, the metadata sidecar and the
+// operations log. Names are paths as used by the os package, not the slash
+// separated, relative names of io/fs.
+type FileSystem interface {
+	Open(name string) (fs.File, error)
+	// Create opens name for writing, truncating it, or creating it with perm
+	Create(name string, perm fs.FileMode) (io.WriteCloser, error)
+	// Append opens name for writing at its end, creating it with perm if needed
+	Append(name string, perm fs.FileMode) (io.WriteCloser, error)
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	Stat(name string) (fs.FileInfo, error)
+	Glob(pattern string) ([]string, error)
+}
+
+// Files is the FileSystem used by the package, the one of the OS by default.
+// Tests and embedders may replace it, e.g. to keep the config in memory.
+var Files FileSystem = OSFileSystem{}
+
+// OSFileSystem is the FileSystem of the operating system
+type OSFileSystem struct{}
+
+func (OSFileSystem) Open(name string) (fs.File, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (OSFileSystem) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (OSFileSystem) Append(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (OSFileSystem) Rename(oldpath, newpath string) error { return os.Rename(oldpath, newpath) }
+
+func (OSFileSystem) Remove(name string) error { return os.Remove(name) }
+
+func (OSFileSystem) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+
+func (OSFileSystem) Glob(pattern string) ([]string, error) { return filepath.Glob(pattern) }
+
+// EvalSymlinks lets atomicWriteFile write through symlinks to their target
+func (OSFileSystem) EvalSymlinks(path string) (string, error) { return filepath.EvalSymlinks(path) }
+
+// readFile returns the content of name, read through Files
+func readFile(name string) ([]byte, error) {
+	file, err := Files.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ProxyGraph is the topology of the ProxyJump relationships of the config:
+// nodes are the hosts that jump or are jumped through, and an edge goes from
+// each hop to the next one on the way to a host
+type ProxyGraph struct {
+	Nodes []ProxyNode
+	Edges []ProxyEdge
+}
+
+// ProxyNode is a host of a ProxyGraph. Hops that no Host block defines have no
+// tags.
+type ProxyNode struct {
+	Name string
+	Tags []string
+}
+
+// ProxyEdge is a connection made through From to reach To
+type ProxyEdge struct {
+	From string
+	To   string
+}
+
+// buildProxyGraph builds the ProxyGraph of the concrete hosts, using the
+// ProxyJump each one resolves to, wildcard entries included
+func buildProxyGraph(hosts []SSHHost) ProxyGraph {
+	var graph ProxyGraph
+	nodes := make(map[string]bool)
+	edges := make(map[ProxyEdge]bool)
+	addNode := func(name string) {
+		if nodes[name] {
+			return
+		}
+		nodes[name] = true
+		node := ProxyNode{Name: name}
+		if host, ok := findHost(hosts, name); ok {
+			node.Tags = host.Tags
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+
+	for _, host := range hosts {
+		if IsPattern(host.Name) {
+			continue
+		}
+		hops := jumpHosts(resolveHost(hosts, host.Name).ProxyJump)
+		if len(hops) == 0 {
+			continue
+		}
+
+		path := append(hops, host.Name)
+		for _, name := range path {
+			addNode(name)
+		}
+		for i := 1; i < len(path); i++ {
+			edge := ProxyEdge{From: path[i-1], To: path[i]}
+			if !edges[edge] {
+				edges[edge] = true
+				graph.Edges = append(graph.Edges, edge)
+			}
+		}
+	}
+	return graph
+}
+
+// BuildProxyGraph returns the ProxyJump topology of the config
+func BuildProxyGraph() (ProxyGraph, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return ProxyGraph{}, err
+	}
+	return buildProxyGraph(hosts), nil
+}
+
+// mermaidLabel quotes text for use as a Mermaid label
+func mermaidLabel(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, "#quot;") + `"`
+}
+
+// ExportProxyGraphMermaid writes the ProxyJump topology of the config as a
+// Mermaid graph, for Markdown documentation. Hosts are grouped into a subgraph
+// per tag, by their first tag as a node can only be in one.
+func ExportProxyGraphMermaid(w io.Writer) error {
+	graph, err := BuildProxyGraph()
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "graph LR")
+
+	ids := make(map[string]string)
+	var tags []string
+	byTag := make(map[string][]ProxyNode)
+	for i, node := range graph.Nodes {
+		ids[node.Name] = fmt.Sprintf("n%d", i)
+		tag := ""
+		if len(node.Tags) > 0 {
+			tag = node.Tags[0]
+		}
+		if _, ok := byTag[tag]; !ok {
+			tags = append(tags, tag)
+		}
+		byTag[tag] = append(byTag[tag], node)
+	}
+
+	for i, tag := range tags {
+		indent := "    "
+		if tag != "" {
+			fmt.Fprintf(out, "    subgraph g%d [%s]\n", i, mermaidLabel(tag))
+			indent = "        "
+		}
+		for _, node := range byTag[tag] {
+			fmt.Fprintf(out, "%s%s[%s]\n", indent, ids[node.Name], mermaidLabel(node.Name))
+		}
+		if tag != "" {
+			fmt.Fprintln(out, "    end")
+		}
+	}
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(out, "    %s --> %s\n", ids[edge.From], ids[edge.To])
+	}
+	return out.Flush()
+}
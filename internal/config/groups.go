@@ -0,0 +1,49 @@
+package config
+
+// ListGroups returns the names of the groups defined in the config, in the
+// order they first appear
+func ListGroups() ([]string, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var groups []string
+	for _, host := range hosts {
+		if host.Group != "" && !seen[host.Group] {
+			seen[host.Group] = true
+			groups = append(groups, host.Group)
+		}
+	}
+	return groups, nil
+}
+
+// GetHostsInGroup returns the hosts belonging to the named group, in config order
+func GetHostsInGroup(name string) ([]SSHHost, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return FilterHosts(hosts, func(host SSHHost) bool { return host.Group == name }), nil
+}
+
+// MoveHostToGroup assigns a host to a group, replacing its previous group.
+// An empty group removes the host from its group.
+func MoveHostToGroup(hostName, group string) error {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+
+	host, err := GetSSHHost(hostName)
+	if err != nil {
+		return err
+	}
+
+	if host.Group == group {
+		return nil
+	}
+	host.Group = group
+	return UpdateSSHHost(hostName, *host)
+}
@@ -0,0 +1,324 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// windowsDrivePath matches absolute Windows paths such as C:\Users\me\.ssh\id_rsa
+var windowsDrivePath = regexp.MustCompile(`^[a-zA-Z]:[\\/]`)
+
+// isWindowsPath reports whether an IdentityFile value is a Windows-style path
+// (drive-letter or UNC), which must not be resolved with the host's path rules
+func isWindowsPath(p string) bool {
+	return windowsDrivePath.MatchString(p) || strings.HasPrefix(p, `\\`)
+}
+
+// normalizeIdentityPath returns a canonical form of an IdentityFile value so that
+// equivalent spellings of the same key (e.g. "~/.ssh/id_rsa" and "/home/me/.ssh/id_rsa")
+// compare equal
+func normalizeIdentityPath(p string) string {
+	p = strings.Trim(strings.TrimSpace(p), "\"")
+
+	// Windows paths are compared with forward slashes and case-insensitively,
+	// whatever platform we are running on
+	if isWindowsPath(p) {
+		return strings.ToLower(path.Clean(strings.ReplaceAll(p, `\`, "/")))
+	}
+
+	if p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, `~\`) {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(homeDir, filepath.FromSlash(strings.ReplaceAll(p[1:], `\`, "/")))
+		}
+	}
+	return filepath.Clean(p)
+}
+
+// SetIdentities sets the identity files of h, keeping Identity in step
+func (h *SSHHost) SetIdentities(identities ...string) {
+	h.Identities = identities
+	h.Identity = ""
+	if len(identities) > 0 {
+		h.Identity = identities[0]
+	}
+}
+
+// hostIdentities returns the identity files of host, with Identity taking
+// precedence over the first of Identities when a caller changed only Identity
+func hostIdentities(host SSHHost) []string {
+	switch {
+	case len(host.Identities) == 0:
+		if host.Identity == "" {
+			return nil
+		}
+		return []string{host.Identity}
+	case host.Identity == host.Identities[0]:
+		return host.Identities
+	case host.Identity == "":
+		return nil
+	}
+	return append([]string{host.Identity}, host.Identities[1:]...)
+}
+
+// ResolvedIdentity returns the IdentityFile of h as an absolute path, with a
+// leading ~ or ~user and the $VAR and ${VAR} environment variables expanded.
+// Relative paths are taken from the working directory. Identity itself keeps
+// the value as written. An empty Identity resolves to "".
+func (h SSHHost) ResolvedIdentity() (string, error) {
+	p := strings.Trim(strings.TrimSpace(h.Identity), "\"")
+	if p == "" {
+		return "", nil
+	}
+
+	var missing []string
+	p = os.Expand(p, func(name string) string {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("IdentityFile '%s' uses undefined environment variable %s", h.Identity, missing[0])
+	}
+
+	if rest, ok := strings.CutPrefix(p, "~"); ok {
+		name, rest, _ := strings.Cut(rest, "/")
+		var homeDir string
+		if name == "" {
+			var err error
+			if homeDir, err = os.UserHomeDir(); err != nil {
+				return "", err
+			}
+		} else {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", fmt.Errorf("IdentityFile '%s': %w", h.Identity, err)
+			}
+			homeDir = u.HomeDir
+		}
+		p = filepath.Join(homeDir, rest)
+	}
+	return filepath.Abs(p)
+}
+
+// dedupeIdentityLines removes repeated IdentityFile directives from a host block,
+// keeping the first occurrence of each key. It returns the filtered block and the
+// number of lines removed.
+func dedupeIdentityLines(block []string) ([]string, int) {
+	seen := make(map[string]bool)
+	var kept []string
+	removed := 0
+
+	for _, line := range block {
+		keyword, value := splitDirective(line)
+		if value != "" && strings.ToLower(keyword) == "identityfile" {
+			key := normalizeIdentityPath(strings.Join(configArgs(value), " "))
+			if seen[key] {
+				removed++
+				continue
+			}
+			seen[key] = true
+		}
+		kept = append(kept, line)
+	}
+
+	return kept, removed
+}
+
+// DedupeIdentities removes duplicate IdentityFile entries from a host's block,
+// preserving the order in which the keys first appear
+func DedupeIdentities(hostName string) (removed int, err error) {
+	if ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return 0, err
+	}
+	if configPath, err = hostFilePath(configPath, hostName); err != nil {
+		return 0, err
+	}
+
+	content, err := readConfigFile(configPath)
+	if err != nil {
+		return 0, err
+	}
+
+	lines := strings.Split(string(content), "\n")
+	start, end, found := findHostBlock(lines, hostName)
+	if !found {
+		return 0, fmt.Errorf("host '%s' not found", hostName)
+	}
+
+	block, removed := dedupeIdentityLines(lines[start:end])
+	if removed == 0 {
+		return 0, nil
+	}
+
+	// Create backup before modification
+	if err := backupConfig(configPath); err != nil {
+		return 0, fmt.Errorf("failed to create backup: %w", err)
+	}
+
+	newLines := append([]string{}, lines[:start]...)
+	newLines = append(newLines, block...)
+	newLines = append(newLines, lines[end:]...)
+
+	return removed, writeConfigFile(configPath, []byte(strings.Join(newLines, "\n")))
+}
+
+// rewriteConfigFiles applies rewrite to the lines of configPath and of every
+// file it includes, and writes back, after a backup, the files where rewrite
+// counted changes. It returns the total count.
+func rewriteConfigFiles(configPath string, rewrite func([]string) ([]string, int)) (total int, err error) {
+	files, err := collectConfigFiles(configPath, make(map[string]bool))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, file := range files {
+		content, err := readConfigFile(file)
+		if err != nil {
+			return total, err
+		}
+		lines, n := rewrite(strings.Split(string(content), "\n"))
+		if n == 0 {
+			continue
+		}
+
+		// Create backup before modification
+		if err := backupConfig(file); err != nil {
+			return total, fmt.Errorf("failed to create backup: %w", err)
+		}
+		if err := writeConfigFile(file, []byte(strings.Join(lines, "\n"))); err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// DedupeAllIdentities removes duplicate IdentityFile entries from every host block
+// of the config and the files it includes, and returns the total number of
+// lines removed
+func DedupeAllIdentities() (removed int, err error) {
+	if ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return 0, err
+	}
+
+	return rewriteConfigFiles(configPath, func(lines []string) ([]string, int) {
+		var newLines []string
+		removed := 0
+		for i := 0; i < len(lines); {
+			if lineKeyword(lines[i]) != "host" {
+				newLines = append(newLines, lines[i])
+				i++
+				continue
+			}
+
+			end := hostBlockEnd(lines, i)
+			block, n := dedupeIdentityLines(lines[i:end])
+			removed += n
+			newLines = append(newLines, block...)
+			i = end
+		}
+		return newLines, removed
+	})
+}
+
+// RotateIdentity replaces every IdentityFile of the config and the files it
+// includes pointing at oldPath, however spelled, with newPath, in a single
+// backed-up write per file. It returns the number of Host blocks that used the
+// key.
+func RotateIdentity(oldPath, newPath string) (affected int, err error) {
+	if ReadOnly {
+		return 0, ErrReadOnly
+	}
+	if strings.TrimSpace(newPath) == "" {
+		return 0, fmt.Errorf("new identity file is empty")
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return 0, err
+	}
+
+	value := newPath
+	if strings.ContainsAny(value, " \t") {
+		value = `"` + value + `"`
+	}
+	oldKey := normalizeIdentityPath(oldPath)
+
+	return rewriteConfigFiles(configPath, func(lines []string) ([]string, int) {
+		affected := 0
+		blockAffected := false
+		for i, line := range lines {
+			keyword, v := splitDirective(strings.TrimSpace(line))
+			switch strings.ToLower(keyword) {
+			case "host", "match":
+				blockAffected = false
+			case "identityfile":
+				if normalizeIdentityPath(strings.Join(configArgs(v), " ")) != oldKey {
+					continue
+				}
+				lines[i] = leadingIndent(line) + keyword + " " + value
+				if !blockAffected {
+					blockAffected = true
+					affected++
+				}
+			}
+		}
+		return lines, affected
+	})
+}
+
+// effectiveIdentities returns the IdentityFile values that apply to hostName, in
+// the order ssh tries them. Unlike most settings, identity files accumulate over
+// all the entries matching the host; duplicate spellings are listed once.
+func effectiveIdentities(hosts []SSHHost, hostName string) []string {
+	var identities []string
+	seen := make(map[string]bool)
+	for _, host := range hosts {
+		if host.Name != hostName && !matchesHost(host.Name, hostName) {
+			continue
+		}
+		for _, value := range hostIdentities(host) {
+			if key := normalizeIdentityPath(value); !seen[key] {
+				seen[key] = true
+				identities = append(identities, value)
+			}
+		}
+	}
+	return identities
+}
+
+// EffectiveIdentities returns the identity files ssh uses for the named host, as
+// written in the config, including those set by wildcard entries
+func EffectiveIdentities(hostName string) ([]string, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+	return effectiveIdentities(hosts, hostName), nil
+}
@@ -0,0 +1,315 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useTestConfigWithInclude sets up a main config including extra.conf, which
+// holds included, and returns the paths of both files
+func useTestConfigWithInclude(t *testing.T, main, included string) (string, string) {
+	t.Helper()
+	path := useTestConfig(t, "Include extra.conf\n\n"+main)
+	extraPath := filepath.Join(filepath.Dir(path), "extra.conf")
+	if err := os.WriteFile(extraPath, []byte(included), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path, extraPath
+}
+
+func TestDedupeIdentitiesIncludedHost(t *testing.T) {
+	mainConfig := "Host web\n    IdentityFile ~/.ssh/id_web\n"
+	path, extraPath := useTestConfigWithInclude(t, mainConfig,
+		"Host db\n    IdentityFile ~/.ssh/id_db\n    IdentityFile ~/.ssh/id_ed25519\n    IdentityFile ~/.ssh/id_db\n")
+
+	removed, err := DedupeIdentities("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("DedupeIdentities() removed %d lines, want 1", removed)
+	}
+
+	want := "Host db\n    IdentityFile ~/.ssh/id_db\n    IdentityFile ~/.ssh/id_ed25519\n"
+	if got := readTestFile(t, extraPath); got != want {
+		t.Errorf("included file = %q, want %q", got, want)
+	}
+	if got := readTestFile(t, path); got != "Include extra.conf\n\n"+mainConfig {
+		t.Errorf("config changed to %q", got)
+	}
+}
+
+func TestDedupeIdentitiesManagedHost(t *testing.T) {
+	useTestConfig(t, "")
+	setForTest(t, &ManagedFile, "gosshm_hosts")
+	host := SSHHost{Name: "db", Hostname: "db.example", Port: "22"}
+	host.SetIdentities("~/.ssh/id_db", "~/.ssh/id_db")
+	if err := AddSSHHost(host); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := DedupeIdentities("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("DedupeIdentities() removed %d lines, want 1", removed)
+	}
+}
+
+func TestRotateIdentity(t *testing.T) {
+	path, extraPath := useTestConfigWithInclude(t,
+		"Host web\n    IdentityFile ~/.ssh/old_key\n\nHost api\n    IdentityFile ~/.ssh/other\n",
+		"Host db\n    IdentityFile=\"~/.ssh/old_key\"\n    IdentityFile ~/.ssh/old_key\n")
+
+	affected, err := RotateIdentity("~/.ssh/old_key", "~/.ssh/new key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if affected != 2 {
+		t.Errorf("RotateIdentity() affected %d hosts, want 2", affected)
+	}
+
+	tests := []struct {
+		path, want string
+	}{
+		{path, "Include extra.conf\n\nHost web\n    IdentityFile \"~/.ssh/new key\"\n\nHost api\n    IdentityFile ~/.ssh/other\n"},
+		{extraPath, "Host db\n    IdentityFile \"~/.ssh/new key\"\n    IdentityFile \"~/.ssh/new key\"\n"},
+	}
+	for _, tt := range tests {
+		if got := readTestFile(t, tt.path); got != tt.want {
+			t.Errorf("%s = %q, want %q", filepath.Base(tt.path), got, tt.want)
+		}
+	}
+}
+
+func TestDedupeIdentityLines(t *testing.T) {
+	tests := []struct {
+		name        string
+		block       []string
+		want        []string
+		wantRemoved int
+	}{
+		{
+			name:        "repeated",
+			block:       []string{"Host web", "    IdentityFile ~/.ssh/a", "    IdentityFile ~/.ssh/b", "    IdentityFile ~/.ssh/a"},
+			want:        []string{"Host web", "    IdentityFile ~/.ssh/a", "    IdentityFile ~/.ssh/b"},
+			wantRemoved: 1,
+		},
+		{
+			name:        "key=value and case",
+			block:       []string{"Host web", "    IdentityFile ~/.ssh/a", "    identityfile=~/.ssh/a", "    IDENTITYFILE = ~/.ssh/a"},
+			want:        []string{"Host web", "    IdentityFile ~/.ssh/a"},
+			wantRemoved: 2,
+		},
+		{
+			name:        "quoted",
+			block:       []string{"Host web", `    IdentityFile "~/.ssh/my key"`, "    IdentityFile ~/.ssh/my key", `    IdentityFile ~/.ssh/"my key"`},
+			want:        []string{"Host web", `    IdentityFile "~/.ssh/my key"`},
+			wantRemoved: 2,
+		},
+		{
+			name:        "distinct keys with spaces",
+			block:       []string{"Host web", `    IdentityFile "~/.ssh/my key"`, `    IdentityFile "~/.ssh/my other key"`},
+			want:        []string{"Host web", `    IdentityFile "~/.ssh/my key"`, `    IdentityFile "~/.ssh/my other key"`},
+			wantRemoved: 0,
+		},
+		{
+			name:        "comments kept",
+			block:       []string{"Host web", "    # IdentityFile ~/.ssh/a", "    IdentityFile ~/.ssh/a"},
+			want:        []string{"Host web", "    # IdentityFile ~/.ssh/a", "    IdentityFile ~/.ssh/a"},
+			wantRemoved: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, "")
+			got, removed := dedupeIdentityLines(tt.block)
+			if !reflect.DeepEqual(got, tt.want) || removed != tt.wantRemoved {
+				t.Errorf("dedupeIdentityLines() = %q, %d, want %q, %d", got, removed, tt.want, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestIsWindowsPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{`C:\Users\me\.ssh\id_rsa`, true},
+		{`c:/Users/me/.ssh/id_rsa`, true},
+		{`\\server\share\id_rsa`, true},
+		{`~/.ssh/id_rsa`, false},
+		{`~\.ssh\id_rsa`, false},
+		{`/home/me/.ssh/id_rsa`, false},
+		{`C:id_rsa`, false},
+		{`host:22`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := isWindowsPath(tt.path); got != tt.want {
+				t.Errorf("isWindowsPath(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeIdentityPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{`C:\Users\me\.ssh\id_rsa`, `c:/users/me/.ssh/id_rsa`, true},
+		{`C:\Users\me\.ssh\id_rsa`, `"C:\Users\me\.ssh\id_rsa"`, true},
+		{`C:\Users\me\.ssh\..\.ssh\id_rsa`, `C:\Users\me\.ssh\id_rsa`, true},
+		{`\\server\share\id_rsa`, `//server/share/id_rsa`, true},
+		{`~\.ssh\id_rsa`, `~/.ssh/id_rsa`, true},
+		{`~/.ssh/id_rsa`, filepath.Join(home, ".ssh", "id_rsa"), true},
+		{`C:\Users\me\.ssh\id_rsa`, `D:\Users\me\.ssh\id_rsa`, false},
+		{`C:\Users\me\.ssh\id_rsa`, `C:\Users\me\.ssh\id_ed25519`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			if got := normalizeIdentityPath(tt.a) == normalizeIdentityPath(tt.b); got != tt.want {
+				t.Errorf("normalizeIdentityPath(%q) == normalizeIdentityPath(%q) is %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowsIdentityFile(t *testing.T) {
+	const config = "Host win\n    HostName win.example\n    Port 2222\n    IdentityFile C:\\Users\\me\\.ssh\\id_rsa\n    IdentityFile \"C:\\Users\\My Name\\.ssh\\id_ed25519\"\n"
+	path := useTestConfig(t, config)
+
+	host, err := GetSSHHost("win")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantIdentities := []string{`C:\Users\me\.ssh\id_rsa`, `"C:\Users\My Name\.ssh\id_ed25519"`}
+	if host.Port != "2222" || !reflect.DeepEqual(host.Identities, wantIdentities) {
+		t.Fatalf("parsed Port %q and Identities %q, want 2222 and %q", host.Port, host.Identities, wantIdentities)
+	}
+
+	host.User = "me"
+	if err := UpdateSSHHost("win", *host); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readTestFile(t, path), config+"    User me\n"; got != want {
+		t.Errorf("config = %q, want %q", got, want)
+	}
+
+	// A repeated key spelled with other separators and case is a duplicate
+	if err := os.WriteFile(path, []byte(config+"    IdentityFile c:/users/me/.ssh/id_rsa\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := DedupeIdentities("win")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, path); removed != 1 || got != config {
+		t.Errorf("DedupeIdentities() removed %d, config = %q, want 1 and %q", removed, got, config)
+	}
+}
+
+func TestUpdateIdentityFile(t *testing.T) {
+	const (
+		noKey   = "Host web\n    HostName web.example\n"
+		oneKey  = "Host web\n    HostName web.example\n    IdentityFile ~/.ssh/id_web\n"
+		twoKeys = "Host web\n    HostName web.example\n    IdentityFile ~/.ssh/id_web\n    IdentityFile ~/.ssh/id_backup\n"
+	)
+	tests := []struct {
+		name   string
+		config string
+		change func(*SSHHost)
+		want   string
+	}{
+		{
+			name:   "added",
+			config: noKey,
+			change: func(h *SSHHost) { h.Identity = "~/.ssh/id_web" },
+			want:   oneKey,
+		},
+		{
+			name:   "changed",
+			config: oneKey,
+			change: func(h *SSHHost) { h.Identity = "~/.ssh/id_new" },
+			want:   strings.Replace(oneKey, "id_web", "id_new", 1),
+		},
+		{
+			name:   "removed",
+			config: oneKey,
+			change: func(h *SSHHost) { h.Identity = "" },
+			want:   noKey,
+		},
+		{
+			name:   "first of two changed",
+			config: twoKeys,
+			change: func(h *SSHHost) { h.Identity = "~/.ssh/id_new" },
+			want:   strings.Replace(twoKeys, "id_web", "id_new", 1),
+		},
+		{
+			name:   "all of two removed",
+			config: twoKeys,
+			change: func(h *SSHHost) { h.Identity = "" },
+			want:   noKey,
+		},
+		{
+			name:   "removed with SetIdentities",
+			config: twoKeys,
+			change: func(h *SSHHost) { h.SetIdentities() },
+			want:   noKey,
+		},
+		{
+			name:   "second of two removed",
+			config: twoKeys,
+			change: func(h *SSHHost) { h.SetIdentities("~/.ssh/id_web") },
+			want:   oneKey,
+		},
+		{
+			name:   "kept on unrelated change",
+			config: twoKeys,
+			change: func(h *SSHHost) { h.User = "deploy" },
+			want:   twoKeys + "    User deploy\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useTestConfig(t, tt.config)
+			host, err := GetSSHHost("web")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.change(host)
+
+			if err := UpdateSSHHost("web", *host); err != nil {
+				t.Fatal(err)
+			}
+			if got := readTestFile(t, path); got != tt.want {
+				t.Errorf("config = %q, want %q", got, tt.want)
+			}
+
+			// Saving the host read back must not bring a removed key back
+			host, err = GetSSHHost("web")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := UpdateSSHHost("web", *host); err != nil {
+				t.Fatal(err)
+			}
+			if got := readTestFile(t, path); got != tt.want {
+				t.Errorf("config after saving again = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
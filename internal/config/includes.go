@@ -0,0 +1,133 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IncludeCycleError is returned when Include directives lead back to a file that
+// is still being read
+type IncludeCycleError struct {
+	Files  []string // The files of the cycle, the first one repeated at the end
+	closed bool
+}
+
+func (e *IncludeCycleError) Error() string {
+	return "Include cycle: " + strings.Join(e.Files, " -> ")
+}
+
+// enterConfigFile records in visited that configPath is being read, and returns
+// its absolute path. It reports false if the file was already read through
+// another Include, and fails with an IncludeCycleError if the file is still
+// being read, i.e. it ends up including itself. Callers mark the file as read
+// by setting visited[absPath] to false once done.
+func enterConfigFile(visited map[string]bool, configPath string) (string, bool, error) {
+	absPath, err := filepath.Abs(configPath)
+	if err != nil {
+		return "", false, err
+	}
+	reading, seen := visited[absPath]
+	if reading {
+		return absPath, false, &IncludeCycleError{Files: []string{absPath}}
+	}
+	if seen {
+		return absPath, false, nil
+	}
+	visited[absPath] = true
+	return absPath, true, nil
+}
+
+// extendIncludeCycle adds the including file absPath to an IncludeCycleError
+// returned while reading its includes, until the cycle is complete
+func extendIncludeCycle(err error, absPath string) error {
+	var cycle *IncludeCycleError
+	if errors.As(err, &cycle) && !cycle.closed {
+		cycle.Files = append([]string{absPath}, cycle.Files...)
+		cycle.closed = absPath == cycle.Files[len(cycle.Files)-1]
+	}
+	return err
+}
+
+// CheckIncludeCycles returns the files of an Include cycle in the config, the
+// first one repeated at the end, or nil if there is none
+func CheckIncludeCycles() ([]string, error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = collectConfigFiles(configPath, make(map[string]bool))
+	var cycle *IncludeCycleError
+	if errors.As(err, &cycle) {
+		return cycle.Files, nil
+	}
+	return nil, err
+}
+
+// includesFile reports whether an Include line of configPath matches path,
+// whether or not the file exists yet
+func includesFile(configPath, path string) (bool, error) {
+	content, err := readConfigFile(configPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		keyword, value := splitDirective(line)
+		if strings.ToLower(keyword) != "include" {
+			continue
+		}
+		for _, arg := range configArgs(value) {
+			pattern, err := includePattern(configPath, arg)
+			if err != nil {
+				return false, err
+			}
+			if ok, _ := filepath.Match(pattern, path); ok {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// ensureInclude makes sure configPath includes the file named by include, an
+// Include argument (relative to the directory of configPath or absolute). The
+// Include line is inserted at the top of the file, since an Include placed
+// after a Host line would only apply to that host.
+func ensureInclude(configPath, include string) error {
+	path, err := includePattern(configPath, include)
+	if err != nil {
+		return err
+	}
+	included, err := includesFile(configPath, path)
+	if err != nil || included {
+		return err
+	}
+
+	content, err := readConfigFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// Create backup before modification if file exists
+	if len(content) > 0 {
+		if err := backupConfig(configPath); err != nil {
+			return fmt.Errorf("failed to create backup: %w", err)
+		}
+	}
+
+	if strings.ContainsAny(include, " \t") {
+		include = `"` + include + `"`
+	}
+	header := "Include " + include + "\n"
+	if len(content) > 0 {
+		header += "\n"
+	}
+	return writeConfigFile(configPath, append([]byte(header), content...))
+}
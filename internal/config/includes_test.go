@@ -0,0 +1,166 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEnsureInclude(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		include string
+		want    string // Empty when the config must be left as is
+	}{
+		{
+			name:    "missing",
+			config:  "Host web\n    HostName web.example\n",
+			include: "gosshm_hosts",
+			want:    "Include gosshm_hosts\n\nHost web\n    HostName web.example\n",
+		},
+		{
+			name:    "empty config",
+			config:  "",
+			include: "gosshm_hosts",
+			want:    "Include gosshm_hosts\n",
+		},
+		{
+			name:    "with spaces",
+			config:  "",
+			include: "my hosts",
+			want:    "Include \"my hosts\"\n",
+		},
+		{
+			name:    "already included",
+			config:  "Include gosshm_hosts\n\nHost web\n",
+			include: "gosshm_hosts",
+		},
+		{
+			name:    "included with key=value syntax",
+			config:  "Include=gosshm_hosts\n",
+			include: "gosshm_hosts",
+		},
+		{
+			name:    "included by glob",
+			config:  "Include gosshm_*\n",
+			include: "gosshm_hosts",
+		},
+		{
+			name:    "included quoted",
+			config:  "Include \"my hosts\"\n",
+			include: "my hosts",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useTestConfig(t, tt.config)
+			if err := ensureInclude(path, tt.include); err != nil {
+				t.Fatal(err)
+			}
+
+			want := tt.want
+			if want == "" {
+				want = tt.config
+			}
+			if got := readTestFile(t, path); got != want {
+				t.Errorf("config = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestEnsureIncludeAbsolutePath(t *testing.T) {
+	path := useTestConfig(t, "Include gosshm_sync\n")
+	if err := ensureInclude(path, filepath.Join(filepath.Dir(path), "gosshm_sync")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, path); strings.Count(got, "Include") != 1 {
+		t.Errorf("config = %q, want a single Include", got)
+	}
+}
+
+func TestCheckIncludeCycles(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		files     map[string]string
+		wantCycle []string // Relative to the config directory
+	}{
+		{
+			name:   "no include",
+			config: "Host web\n",
+		},
+		{
+			name:   "shared include",
+			config: "Include a.conf b.conf\n",
+			files:  map[string]string{"a.conf": "Include c.conf\n", "b.conf": "Include c.conf\n", "c.conf": "Host c\n"},
+		},
+		{
+			name:   "missing include",
+			config: "Include missing.conf\n",
+		},
+		{
+			name:      "two files",
+			config:    "Include a.conf\n",
+			files:     map[string]string{"a.conf": "Include b.conf\n", "b.conf": "Host b\nInclude a.conf\n"},
+			wantCycle: []string{"a.conf", "b.conf", "a.conf"},
+		},
+		{
+			name:      "three files",
+			config:    "Host web\n\nInclude a.conf\n",
+			files:     map[string]string{"a.conf": "Include b.conf\n", "b.conf": "Include c.conf\n", "c.conf": "Include a.conf\n"},
+			wantCycle: []string{"a.conf", "b.conf", "c.conf", "a.conf"},
+		},
+		{
+			name:      "self include",
+			config:    "Include a.conf\n",
+			files:     map[string]string{"a.conf": "Include a.conf\n"},
+			wantCycle: []string{"a.conf", "a.conf"},
+		},
+		{
+			name:      "back to the config",
+			config:    "Include a.conf\n",
+			files:     map[string]string{"a.conf": "Include config\n"},
+			wantCycle: []string{"config", "a.conf", "config"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useTestConfig(t, tt.config)
+			dir := filepath.Dir(path)
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var wantCycle []string
+			for _, name := range tt.wantCycle {
+				wantCycle = append(wantCycle, filepath.Join(dir, name))
+			}
+
+			cycle, err := CheckIncludeCycles()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cycle, wantCycle) {
+				t.Errorf("CheckIncludeCycles() = %q, want %q", cycle, wantCycle)
+			}
+
+			_, err = ParseSSHConfig()
+			var cycleErr *IncludeCycleError
+			if tt.wantCycle == nil {
+				if err != nil {
+					t.Errorf("ParseSSHConfig() error = %v", err)
+				}
+			} else if !errors.As(err, &cycleErr) || !reflect.DeepEqual(cycleErr.Files, wantCycle) {
+				t.Errorf("ParseSSHConfig() error = %v, want an Include cycle through %q", err, wantCycle)
+			}
+		})
+	}
+}
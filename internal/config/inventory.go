@@ -0,0 +1,224 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// inventoryHost is the form of a host in ExportHosts files. The location of
+// the block is left out, as it means nothing on another machine.
+type inventoryHost struct {
+	Name                  string              `json:"name" yaml:"name"`
+	Hostname              string              `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	User                  string              `json:"user,omitempty" yaml:"user,omitempty"`
+	Port                  string              `json:"port,omitempty" yaml:"port,omitempty"`
+	Identities            []string            `json:"identities,omitempty" yaml:"identities,omitempty"`
+	ProxyJump             string              `json:"proxy_jump,omitempty" yaml:"proxy_jump,omitempty"`
+	ProxyCommand          string              `json:"proxy_command,omitempty" yaml:"proxy_command,omitempty"`
+	ControlMaster         string              `json:"control_master,omitempty" yaml:"control_master,omitempty"`
+	ControlPath           string              `json:"control_path,omitempty" yaml:"control_path,omitempty"`
+	Ciphers               string              `json:"ciphers,omitempty" yaml:"ciphers,omitempty"`
+	MACs                  string              `json:"macs,omitempty" yaml:"macs,omitempty"`
+	KexAlgorithms         string              `json:"kex_algorithms,omitempty" yaml:"kex_algorithms,omitempty"`
+	AddressFamily         string              `json:"address_family,omitempty" yaml:"address_family,omitempty"`
+	BindAddress           string              `json:"bind_address,omitempty" yaml:"bind_address,omitempty"`
+	ForwardX11            string              `json:"forward_x11,omitempty" yaml:"forward_x11,omitempty"`
+	ForwardX11Trusted     string              `json:"forward_x11_trusted,omitempty" yaml:"forward_x11_trusted,omitempty"`
+	DynamicForwards       []string            `json:"dynamic_forwards,omitempty" yaml:"dynamic_forwards,omitempty"`
+	LocalForwards         []string            `json:"local_forwards,omitempty" yaml:"local_forwards,omitempty"`
+	RemoteForwards        []string            `json:"remote_forwards,omitempty" yaml:"remote_forwards,omitempty"`
+	ForwardAgent          string              `json:"forward_agent,omitempty" yaml:"forward_agent,omitempty"`
+	ServerAliveInterval   string              `json:"server_alive_interval,omitempty" yaml:"server_alive_interval,omitempty"`
+	StrictHostKeyChecking string              `json:"strict_host_key_checking,omitempty" yaml:"strict_host_key_checking,omitempty"`
+	ConnectTimeout        string              `json:"connect_timeout,omitempty" yaml:"connect_timeout,omitempty"`
+	Comments              []string            `json:"comments,omitempty" yaml:"comments,omitempty"`
+	Tags                  []string            `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Group                 string              `json:"group,omitempty" yaml:"group,omitempty"`
+	Meta                  map[string]string   `json:"meta,omitempty" yaml:"meta,omitempty"`
+	Profiles              map[string]string   `json:"profiles,omitempty" yaml:"profiles,omitempty"`
+	Launcher              string              `json:"launcher,omitempty" yaml:"launcher,omitempty"`
+	Options               []string            `json:"options,omitempty" yaml:"options,omitempty"`
+	Extra                 map[string][]string `json:"extra,omitempty" yaml:"extra,omitempty"`
+}
+
+// toInventory converts host to its inventory form
+func toInventory(host SSHHost) inventoryHost {
+	return inventoryHost{
+		Name: host.Name, Hostname: host.Hostname, User: host.User, Port: host.Port,
+		Identities: hostIdentities(host), ProxyJump: host.ProxyJump, ProxyCommand: host.ProxyCommand,
+		ControlMaster: host.ControlMaster, ControlPath: host.ControlPath,
+		Ciphers: host.Ciphers, MACs: host.MACs, KexAlgorithms: host.KexAlgorithms,
+		AddressFamily: host.AddressFamily, BindAddress: host.BindAddress,
+		ForwardX11: host.ForwardX11, ForwardX11Trusted: host.ForwardX11Trusted,
+		DynamicForwards: host.DynamicForwards, LocalForwards: host.LocalForwards,
+		RemoteForwards: host.RemoteForwards, ForwardAgent: host.ForwardAgent,
+		ServerAliveInterval: host.ServerAliveInterval, StrictHostKeyChecking: host.StrictHostKeyChecking,
+		ConnectTimeout: host.ConnectTimeout, Comments: host.Comments, Tags: host.Tags,
+		Group: host.Group, Meta: host.Meta, Profiles: host.Profiles,
+		Launcher: host.Launcher, Options: host.Options, Extra: host.Extra,
+	}
+}
+
+// toHost converts an inventory host back to an SSHHost
+func (h inventoryHost) toHost() SSHHost {
+	host := SSHHost{
+		Name: h.Name, Hostname: h.Hostname, User: h.User, Port: h.Port,
+		ProxyJump: h.ProxyJump, ProxyCommand: h.ProxyCommand,
+		ControlMaster: h.ControlMaster, ControlPath: h.ControlPath,
+		Ciphers: h.Ciphers, MACs: h.MACs, KexAlgorithms: h.KexAlgorithms,
+		AddressFamily: h.AddressFamily, BindAddress: h.BindAddress,
+		ForwardX11: h.ForwardX11, ForwardX11Trusted: h.ForwardX11Trusted,
+		DynamicForwards: h.DynamicForwards, LocalForwards: h.LocalForwards,
+		RemoteForwards: h.RemoteForwards, ForwardAgent: h.ForwardAgent,
+		ServerAliveInterval: h.ServerAliveInterval, StrictHostKeyChecking: h.StrictHostKeyChecking,
+		ConnectTimeout: h.ConnectTimeout, Comments: h.Comments, Tags: h.Tags,
+		Group: h.Group, Meta: h.Meta, Profiles: h.Profiles,
+		Launcher: h.Launcher, Options: h.Options, Extra: h.Extra,
+		Pattern: IsPattern(h.Name),
+	}
+	host.SetIdentities(h.Identities...)
+	return host
+}
+
+// ExportHosts writes hosts to w as "json" or "yaml", with every setting, tags
+// and other gosshm metadata included, to be read back by ImportHosts
+func ExportHosts(hosts []SSHHost, w io.Writer, format string) error {
+	inventory := make([]inventoryHost, len(hosts))
+	for i, host := range hosts {
+		inventory[i] = toInventory(host)
+	}
+
+	switch strings.ToLower(format) {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(inventory)
+	case "yaml", "yml":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(inventory); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+	return fmt.Errorf("unsupported format '%s': expected json or yaml", format)
+}
+
+// ImportHosts reads hosts written by ExportHosts in format. The hosts are not
+// added to the config.
+func ImportHosts(r io.Reader, format string) ([]SSHHost, error) {
+	// Unknown keys are rejected rather than silently dropped, e.g. misspelled
+	// ones in a hand-edited file
+	var inventory []inventoryHost
+	switch strings.ToLower(format) {
+	case "json":
+		decoder := json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&inventory); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+	case "yaml", "yml":
+		decoder := yaml.NewDecoder(r)
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&inventory); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format '%s': expected json or yaml", format)
+	}
+
+	hosts := make([]SSHHost, len(inventory))
+	for i, h := range inventory {
+		if strings.TrimSpace(h.Name) == "" {
+			return nil, fmt.Errorf("host %d has no name", i+1)
+		}
+		hosts[i] = h.toHost()
+	}
+	return hosts, nil
+}
+
+// ImportStrategy is what ImportConfig does with a host whose name is taken
+type ImportStrategy string
+
+const (
+	// ImportSkip keeps the existing host and drops the imported one
+	ImportSkip ImportStrategy = "skip"
+	// ImportOverwrite replaces the settings of the existing host
+	ImportOverwrite ImportStrategy = "overwrite"
+	// ImportRename adds the imported host under the first free name with a
+	// numbered suffix (web-2, web-3...)
+	ImportRename ImportStrategy = "rename"
+)
+
+// ImportReport lists what ImportConfig did with each host, by name
+type ImportReport struct {
+	Added       []string // Including renamed hosts, under their new name
+	Skipped     []string
+	Overwritten []string
+	Renamed     map[string]string // Imported name to the name it was added as
+}
+
+// ImportConfig adds hosts, e.g. read with ImportHosts, to the config one by one
+// with AddSSHHost, handling names already in use with strategy. On error, the
+// report covers the hosts imported so far.
+func ImportConfig(hosts []SSHHost, strategy ImportStrategy) (ImportReport, error) {
+	report := ImportReport{Renamed: make(map[string]string)}
+	switch strategy {
+	case ImportSkip, ImportOverwrite, ImportRename:
+	default:
+		return report, fmt.Errorf("unknown import strategy '%s'", strategy)
+	}
+	if ReadOnly {
+		return report, ErrReadOnly
+	}
+
+	existing, err := ParseSSHConfig()
+	if err != nil && !os.IsNotExist(err) {
+		return report, err
+	}
+	taken := make(map[string]bool, len(existing))
+	for _, host := range existing {
+		taken[host.Name] = true
+	}
+
+	for _, host := range hosts {
+		host.SourceFile, host.LineNumber, host.EndLine = "", 0, 0
+		if !taken[host.Name] {
+			if err := AddSSHHost(host); err != nil {
+				return report, err
+			}
+			taken[host.Name] = true
+			report.Added = append(report.Added, host.Name)
+			continue
+		}
+
+		switch strategy {
+		case ImportSkip:
+			report.Skipped = append(report.Skipped, host.Name)
+		case ImportOverwrite:
+			if err := UpdateSSHHost(host.Name, host); err != nil {
+				return report, err
+			}
+			report.Overwritten = append(report.Overwritten, host.Name)
+		case ImportRename:
+			name := host.Name
+			for n := 2; taken[name]; n++ {
+				name = fmt.Sprintf("%s-%d", host.Name, n)
+			}
+			report.Renamed[host.Name] = name
+			host.Name = name
+			if err := AddSSHHost(host); err != nil {
+				return report, err
+			}
+			taken[name] = true
+			report.Added = append(report.Added, name)
+		}
+	}
+	return report, nil
+}
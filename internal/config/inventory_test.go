@@ -0,0 +1,86 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// inventoryHosts are hosts with every kind of field ExportHosts writes,
+// including values that need quoting or escaping
+var inventoryHosts = []SSHHost{
+	{
+		Name: "web", Hostname: "web.example", User: "deploy", Port: "2222",
+		Identities: []string{"~/.ssh/id_web", `C:\Users\me\.ssh\id rsa`}, Identity: "~/.ssh/id_web",
+		ProxyJump: "bastion", ProxyCommand: `sh -c "nc %h %p"`,
+		LocalForwards: []string{"8080 localhost:80"}, ForwardAgent: "yes",
+		Comments: []string{"# front: \"main\" site", "#\ttab\\0"},
+		Tags:     []string{"web", "prod"}, Group: "frontend",
+		Meta:     map[string]string{"owner": "ops # team", "empty": "", "multi": "line one\nline two"},
+		Profiles: map[string]string{"dev": "10.0.1.5"},
+		Options:  []string{"StrictHostKeyChecking=no"},
+		Extra:    map[string][]string{"SendEnv": {"LANG", "LC_*"}},
+	},
+	{Name: "yes", Hostname: "1.5", Port: "22", User: "null", Launcher: "  - {name}: ~"},
+	{Name: "*.internal", User: "admin", Pattern: true},
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, format := range []string{"json", "yaml"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := ExportHosts(inventoryHosts, &buf, format); err != nil {
+				t.Fatal(err)
+			}
+			hosts, err := ImportHosts(&buf, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(hosts, inventoryHosts) {
+				t.Errorf("ImportHosts() = %+v, want %+v", hosts, inventoryHosts)
+			}
+		})
+	}
+}
+
+func TestImportHostsYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []SSHHost
+		wantErr bool
+	}{
+		{
+			name:  "plain scalars",
+			input: "- name: web\n  hostname: 10.0.0.1\n  port: 2222\n  tags: [web, prod]\n",
+			want:  []SSHHost{{Name: "web", Hostname: "10.0.0.1", Port: "2222", Tags: []string{"web", "prod"}}},
+		},
+		{
+			name:  "escapes",
+			input: "- name: web\n  meta:\n    note: \"nul\\0 esc\\e nl\\N\"\n",
+			want:  []SSHHost{{Name: "web", Meta: map[string]string{"note": "nul\x00 esc\x1b nl\u0085"}}},
+		},
+		{
+			name:  "multi-line scalars",
+			input: "- name: web\n  comments:\n    - |-\n      # first\n      # second\n  launcher: >-\n    ssh\n    {host}\n",
+			want:  []SSHHost{{Name: "web", Comments: []string{"# first\n# second"}, Launcher: "ssh {host}"}},
+		},
+		{name: "empty", input: "", want: []SSHHost{}},
+		{name: "unknown key", input: "- name: web\n  hostnme: typo\n", wantErr: true},
+		{name: "missing name", input: "- hostname: web.example\n", wantErr: true},
+		{name: "not a list", input: "name: web\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts, err := ImportHosts(strings.NewReader(tt.input), "yaml")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ImportHosts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(hosts, tt.want) {
+				t.Errorf("ImportHosts() = %+v, want %+v", hosts, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// opensshKeyMagic starts the content of keys in the OpenSSH private key format
+const opensshKeyMagic = "openssh-key-v1\x00"
+
+// KeyPairIssue is a problem with an IdentityFile of a host and its public half
+type KeyPairIssue struct {
+	Identity string
+	Problem  string
+}
+
+// sshString encodes b as an SSH wire format string
+func sshString(b []byte) []byte {
+	buf := binary.BigEndian.AppendUint32(nil, uint32(len(b)))
+	return append(buf, b...)
+}
+
+// sshMpint encodes a non-negative n as an SSH wire format mpint
+func sshMpint(n *big.Int) []byte {
+	b := n.Bytes()
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return sshString(b)
+}
+
+// readSSHString reads an SSH wire format string from the start of data
+func readSSHString(data []byte) ([]byte, []byte, error) {
+	if len(data) < 4 {
+		return nil, nil, errors.New("truncated key")
+	}
+	n := binary.BigEndian.Uint32(data)
+	if uint32(len(data)-4) < n {
+		return nil, nil, errors.New("truncated key")
+	}
+	return data[4 : 4+n], data[4+n:], nil
+}
+
+// marshalPublicKey encodes a public key in the SSH wire format used by .pub files
+func marshalPublicKey(pub any) ([]byte, error) {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		blob := sshString([]byte("ssh-rsa"))
+		blob = append(blob, sshMpint(big.NewInt(int64(key.E)))...)
+		return append(blob, sshMpint(key.N)...), nil
+	case *ecdsa.PublicKey:
+		curve := fmt.Sprintf("nistp%d", key.Curve.Params().BitSize)
+		point, err := key.ECDH()
+		if err != nil {
+			return nil, err
+		}
+		blob := sshString([]byte("ecdsa-sha2-" + curve))
+		blob = append(blob, sshString([]byte(curve))...)
+		return append(blob, sshString(point.Bytes())...), nil
+	case ed25519.PublicKey:
+		return append(sshString([]byte("ssh-ed25519")), sshString(key)...), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", pub)
+}
+
+// privateKeyPublicBlob returns the public key blob of a private key file. Keys
+// in the OpenSSH format carry it in clear, even when encrypted; PEM keys must
+// not be encrypted for it to be derived.
+func privateKeyPublicBlob(content []byte) ([]byte, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("not a PEM encoded private key")
+	}
+
+	if block.Type == "OPENSSH PRIVATE KEY" {
+		data := block.Bytes
+		if !bytes.HasPrefix(data, []byte(opensshKeyMagic)) {
+			return nil, errors.New("invalid OpenSSH private key")
+		}
+		data = data[len(opensshKeyMagic):]
+		// Skip the cipher name, KDF name and KDF options
+		for i := 0; i < 3; i++ {
+			var err error
+			if _, data, err = readSSHString(data); err != nil {
+				return nil, err
+			}
+		}
+		if len(data) < 4 || binary.BigEndian.Uint32(data) < 1 {
+			return nil, errors.New("OpenSSH private key holds no key")
+		}
+		blob, _, err := readSSHString(data[4:])
+		return blob, err
+	}
+
+	if x509.IsEncryptedPEMBlock(block) {
+		return nil, errors.New("encrypted PEM key")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(interface{ Public() crypto.PublicKey })
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return marshalPublicKey(signer.Public())
+}
+
+// publicKeyBlob returns the key blob of a .pub file ("type base64 [comment]")
+func publicKeyBlob(content []byte) ([]byte, error) {
+	fields := strings.Fields(string(content))
+	if len(fields) < 2 {
+		return nil, errors.New("invalid public key file")
+	}
+	return base64.StdEncoding.DecodeString(fields[1])
+}
+
+// VerifyKeyPair checks, for each IdentityFile of host, that the private key
+// exists, that its .pub counterpart exists, and that both halves belong to the
+// same key. Keys whose public half cannot be derived (encrypted PEM keys) are
+// only checked for the presence of their .pub file.
+func VerifyKeyPair(host SSHHost) ([]KeyPairIssue, error) {
+	identities := hostIdentities(host)
+
+	var issues []KeyPairIssue
+	for _, identity := range identities {
+		path := expandControlPath(strings.Trim(identity, "\""), host)
+		report := func(problem string) {
+			issues = append(issues, KeyPairIssue{Identity: identity, Problem: problem})
+		}
+
+		private, err := readFile(path)
+		if os.IsNotExist(err) {
+			report("private key not found")
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		public, err := readFile(path + ".pub")
+		if os.IsNotExist(err) {
+			report("public key " + path + ".pub not found")
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		pubBlob, err := publicKeyBlob(public)
+		if err != nil {
+			report("unreadable public key: " + err.Error())
+			continue
+		}
+		privBlob, err := privateKeyPublicBlob(private)
+		if err != nil {
+			// The private half cannot be checked, which is not an issue by itself
+			continue
+		}
+		if !bytes.Equal(pubBlob, privBlob) {
+			report("public key does not match the private key")
+		}
+	}
+	return issues, nil
+}
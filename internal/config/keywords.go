@@ -0,0 +1,86 @@
+package config
+
+import "strings"
+
+// clientKeywords lists the keywords of ssh_config(5), as spelled in the manual
+var clientKeywords = []string{
+	"Host", "Match", "AddKeysToAgent", "AddressFamily", "BatchMode", "BindAddress",
+	"BindInterface", "CanonicalDomains", "CanonicalizeFallbackLocal", "CanonicalizeHostname",
+	"CanonicalizeMaxDots", "CanonicalizePermittedCNAMEs", "CASignatureAlgorithms",
+	"CertificateFile", "ChannelTimeout", "CheckHostIP", "Ciphers", "ClearAllForwardings",
+	"Compression", "ConnectionAttempts", "ConnectTimeout", "ControlMaster", "ControlPath",
+	"ControlPersist", "DynamicForward", "EnableEscapeCommandline", "EnableSSHKeysign",
+	"EscapeChar", "ExitOnForwardFailure", "FingerprintHash", "ForkAfterAuthentication",
+	"ForwardAgent", "ForwardX11", "ForwardX11Timeout", "ForwardX11Trusted", "GatewayPorts",
+	"GlobalKnownHostsFile", "GSSAPIAuthentication", "GSSAPIDelegateCredentials",
+	"HashKnownHosts", "HostbasedAcceptedAlgorithms", "HostbasedAuthentication",
+	"HostbasedKeyTypes", "HostKeyAlgorithms", "HostKeyAlias", "HostName", "IdentitiesOnly",
+	"IdentityAgent", "IdentityFile", "IgnoreUnknown", "Include", "IPQoS",
+	"KbdInteractiveAuthentication", "KbdInteractiveDevices", "KexAlgorithms",
+	"KnownHostsCommand", "LocalCommand", "LocalForward", "LogLevel", "LogVerbose", "MACs",
+	"NoHostAuthenticationForLocalhost", "NumberOfPasswordPrompts", "ObscureKeystrokeTiming",
+	"PasswordAuthentication", "PermitLocalCommand", "PermitRemoteOpen", "PKCS11Provider",
+	"Port", "PreferredAuthentications", "ProxyCommand", "ProxyJump", "ProxyUseFdpass",
+	"PubkeyAcceptedAlgorithms", "PubkeyAcceptedKeyTypes", "PubkeyAuthentication",
+	"RefuseConnection", "RekeyLimit", "RemoteCommand", "RemoteForward", "RequestTTY",
+	"RequiredRSASize", "RevokedHostKeys", "SecurityKeyProvider", "SendEnv",
+	"ServerAliveCountMax", "ServerAliveInterval", "SessionType", "SetEnv", "StdinNull",
+	"StreamLocalBindMask", "StreamLocalBindUnlink", "StrictHostKeyChecking",
+	"SyslogFacility", "Tag", "TCPKeepAlive", "Tunnel", "TunnelDevice", "UpdateHostKeys",
+	"User", "UserKnownHostsFile", "VerifyHostKeyDNS", "VisualHostKey", "WarnWeakCrypto",
+	"XAuthLocation",
+	// Added by the patches of common distributions and of macOS
+	"GSSAPIClientIdentity", "GSSAPIKeyExchange", "GSSAPIKexAlgorithms",
+	"GSSAPIRenewalForcesRekey", "GSSAPIServerIdentity", "GSSAPITrustDns", "UseKeychain",
+}
+
+// knownKeywords maps the lowercased keywords of clientKeywords to their spelling
+var knownKeywords = func() map[string]string {
+	known := make(map[string]string, len(clientKeywords))
+	for _, keyword := range clientKeywords {
+		known[strings.ToLower(keyword)] = keyword
+	}
+	return known
+}()
+
+// isKnownKeyword reports whether ssh accepts keyword, deprecated ones included
+func isKnownKeyword(keyword string) bool {
+	key := strings.ToLower(keyword)
+	_, known := knownKeywords[key]
+	_, deprecated := deprecatedDirectives[key]
+	return known || deprecated
+}
+
+// suggestKeyword returns the known keyword closest to an unknown one, if any is
+// within two edits of it
+func suggestKeyword(keyword string) string {
+	key := strings.ToLower(keyword)
+	best, bestDistance := "", 3
+	for _, candidate := range clientKeywords {
+		if d := editDistance(key, strings.ToLower(candidate)); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
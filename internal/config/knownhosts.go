@@ -0,0 +1,159 @@
+package config
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// hashedHostPrefix starts the host field of entries hashed by HashKnownHosts
+const hashedHostPrefix = "|1|"
+
+// KnownHostEntry is a line of the known_hosts file, with the configured hosts
+// it corresponds to
+type KnownHostEntry struct {
+	Line        int
+	Marker      string   // @cert-authority or @revoked, if any
+	Hashed      bool     // Host names are hashed and not readable
+	Hosts       []string // Host patterns as written, for entries that are not hashed
+	KeyType     string
+	ConfigHosts []string // Configured hosts whose HostName and Port match the entry
+}
+
+// knownHostName returns the name ssh looks up in known_hosts for an address:
+// the host alone on port 22, "[host]:port" otherwise
+func knownHostName(hostname, port string) string {
+	if port == "" || port == "22" {
+		return hostname
+	}
+	return "[" + hostname + "]:" + port
+}
+
+// matchesHashedHost reports whether a hashed host field (|1|salt|hash) is the
+// hash of name
+func matchesHashedHost(field, name string) bool {
+	parts := strings.Split(strings.TrimPrefix(field, hashedHostPrefix), "|")
+	if len(parts) != 2 {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	hash, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(name))
+	return hmac.Equal(mac.Sum(nil), hash)
+}
+
+// matchesKnownHostPatterns reports whether name is matched by the comma-separated
+// patterns of a known_hosts entry that is not hashed. Brackets are literal
+// there ("[host]:port"), so only patterns with wildcards are globbed.
+func matchesKnownHostPatterns(patterns, name string) bool {
+	matched := false
+	for _, pattern := range strings.Split(patterns, ",") {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+
+		ok := pattern == name
+		if !ok && strings.ContainsAny(pattern, "*?") {
+			ok, _ = path.Match(pattern, name)
+		}
+		if !ok {
+			continue
+		}
+		if negated {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+// ListKnownHosts reads ~/.ssh/known_hosts and ties each entry to the configured
+// hosts whose HostName and Port it is for. Hashed entries, unreadable as such,
+// are matched by hashing the address of each configured host.
+func ListKnownHosts() ([]KnownHostEntry, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string][]string)
+	var lookups []string
+	for _, host := range hosts {
+		if IsPattern(host.Name) {
+			continue
+		}
+		resolved := resolveHost(hosts, host.Name)
+		lookup := knownHostName(resolved.Hostname, resolved.Port)
+		if _, ok := names[lookup]; !ok {
+			lookups = append(lookups, lookup)
+		}
+		names[lookup] = append(names[lookup], host.Name)
+	}
+
+	file, err := Files.Open(filepath.Join(homeDir, ".ssh", "known_hosts"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []KnownHostEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		entry := KnownHostEntry{Line: lineNum}
+		if strings.HasPrefix(fields[0], "@") {
+			entry.Marker = fields[0]
+			fields = fields[1:]
+		}
+		if len(fields) < 2 {
+			continue
+		}
+		entry.KeyType = fields[1]
+
+		hostField := fields[0]
+		entry.Hashed = strings.HasPrefix(hostField, hashedHostPrefix)
+		if !entry.Hashed {
+			entry.Hosts = strings.Split(hostField, ",")
+		}
+
+		for _, lookup := range lookups {
+			var matched bool
+			if entry.Hashed {
+				matched = matchesHashedHost(hostField, lookup)
+			} else {
+				matched = matchesKnownHostPatterns(hostField, lookup)
+			}
+			if matched {
+				entry.ConfigHosts = append(entry.ConfigHosts, names[lookup]...)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,50 @@
+package config
+
+import "os"
+
+// ManagedFile is a file, included by the main config, that AddSSHHost writes new
+// hosts to instead of the main config, which is then only touched to add the
+// Include line. A relative path is relative to the directory of the main
+// config, as in Include. The file and the Include line are created by the
+// first add. Hosts defined in the file are updated and deleted there; reading
+// merges every file as usual.
+var ManagedFile string
+
+// managedFilePath returns the path of ManagedFile for configPath, or an empty
+// string when it is not set
+func managedFilePath(configPath string) (string, error) {
+	if ManagedFile == "" {
+		return "", nil
+	}
+	return includePattern(configPath, ManagedFile)
+}
+
+// newHostFilePath returns the file new hosts are written to: ManagedFile, once
+// configPath includes it, when set, configPath otherwise
+func newHostFilePath(configPath string) (string, error) {
+	managedPath, err := managedFilePath(configPath)
+	if err != nil || managedPath == "" {
+		return configPath, err
+	}
+	if err := ensureInclude(configPath, ManagedFile); err != nil {
+		return "", err
+	}
+	return managedPath, nil
+}
+
+// hostFilePath returns the file holding the block of hostName: configPath or
+// the file it includes, such as ManagedFile, that defines the host. It is
+// configPath when no file does.
+func hostFilePath(configPath, hostName string) (string, error) {
+	hosts, err := parseSSHConfigFile(configPath, make(map[string]bool))
+	if os.IsNotExist(err) {
+		return configPath, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if host, ok := findHost(hosts, hostName); ok && host.SourceFile != "" {
+		return host.SourceFile, nil
+	}
+	return configPath, nil
+}
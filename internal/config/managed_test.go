@@ -0,0 +1,68 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManagedFile(t *testing.T) {
+	path := useTestConfig(t, "Host web\n    HostName web.example\n")
+	setForTest(t, &ManagedFile, "gosshm_hosts")
+	managedPath := filepath.Join(filepath.Dir(path), "gosshm_hosts")
+
+	if err := AddSSHHost(SSHHost{Name: "db", Hostname: "db.example", Port: "22"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddSSHHost(SSHHost{Name: "cache", Hostname: "cache.example", Port: "22"}); err != nil {
+		t.Fatal(err)
+	}
+
+	wantConfig := "Include gosshm_hosts\n\nHost web\n    HostName web.example\n"
+	if got := readTestFile(t, path); got != wantConfig {
+		t.Errorf("config = %q, want %q", got, wantConfig)
+	}
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db := hostNamed(t, hosts, "db"); db.SourceFile != managedPath {
+		t.Errorf("db defined in %s, want %s", db.SourceFile, managedPath)
+	}
+
+	// Hosts of the managed file are updated and deleted there
+	if err := UpdateSSHHost("db", SSHHost{Name: "db", Hostname: "db2.example", Port: "22"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteSSHHost("cache"); err != nil {
+		t.Fatal(err)
+	}
+	managed := readTestFile(t, managedPath)
+	if !strings.Contains(managed, "HostName db2.example") || strings.Contains(managed, "cache") {
+		t.Errorf("managed file = %q, want db updated and cache deleted", managed)
+	}
+	if got := readTestFile(t, path); got != wantConfig {
+		t.Errorf("config changed to %q by writes to the managed file", got)
+	}
+}
+
+func TestHostFilePath(t *testing.T) {
+	path, extraPath := useTestConfigWithInclude(t, "Host web\n    HostName web.example\n", "Host db\n    HostName db.example\n")
+
+	tests := []struct {
+		host, want string
+	}{
+		{"web", path},
+		{"db", extraPath},
+		{"missing", path},
+	}
+	for _, tt := range tests {
+		got, err := hostFilePath(path, tt.host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("hostFilePath(%q) = %s, want %s", tt.host, got, tt.want)
+		}
+	}
+}
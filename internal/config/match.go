@@ -0,0 +1,315 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"strings"
+)
+
+// IsPattern reports whether a Host entry is a pattern (wildcards, negations or
+// several names) rather than a single concrete host
+func IsPattern(name string) bool {
+	return strings.ContainsAny(name, "*?! \t")
+}
+
+// matchesHost reports whether hostName is matched by the patterns of a Host
+// line, following ssh_config rules: any negated pattern that matches excludes
+// the host, otherwise one positive match is enough
+func matchesHost(patterns, hostName string) bool {
+	matched := false
+	for _, pattern := range strings.Fields(patterns) {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+
+		ok, err := path.Match(pattern, hostName)
+		if err != nil || !ok {
+			continue
+		}
+		if negated {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+// matchMayApply reports whether the criteria of a Match line may select host.
+// Only host, originalhost and all can be evaluated without connecting; the
+// other criteria are assumed to hold.
+func matchMayApply(line string, host SSHHost) bool {
+	_, value := splitDirective(line)
+	fields := configArgs(value)
+	for i := 0; i < len(fields); i++ {
+		criterion := strings.ToLower(fields[i])
+		negated := strings.HasPrefix(criterion, "!")
+		criterion = strings.TrimPrefix(criterion, "!")
+
+		var ok bool
+		switch criterion {
+		case "all", "canonical", "final":
+			continue
+		case "host", "originalhost":
+			if i+1 >= len(fields) {
+				return true
+			}
+			i++
+			patterns := strings.ReplaceAll(fields[i], ",", " ")
+			ok = matchesHost(patterns, host.Name)
+			if criterion == "host" && host.Hostname != "" {
+				ok = ok || matchesHost(patterns, host.Hostname)
+			}
+		default:
+			// Criteria such as exec or user take an argument and can't be decided
+			i++
+			continue
+		}
+		if ok == negated {
+			return false
+		}
+	}
+	return true
+}
+
+// trailingMatchIndex returns the index of the first line of the Match blocks
+// that end lines, including the comments right above them, when one of them
+// may apply to host
+func trailingMatchIndex(lines []string, host SSHHost) (int, bool) {
+	index, applies := -1, false
+	for i, line := range lines {
+		switch lineKeyword(line) {
+		case "host":
+			index, applies = -1, false
+		case "match":
+			if index < 0 {
+				index = i
+			}
+			applies = applies || matchMayApply(strings.TrimSpace(line), host)
+		}
+	}
+	if index < 0 || !applies {
+		return 0, false
+	}
+	for index > 0 && strings.HasPrefix(strings.TrimSpace(lines[index-1]), "#") {
+		index--
+	}
+	return index, true
+}
+
+// splitHostPatterns turns the entry of a Host line with several patterns into
+// one entry per literal host name, sharing its settings, followed by an entry
+// holding the wildcard patterns, if any. Names excluded by a negated pattern of
+// the line are left out, as ssh never applies the block to them.
+func splitHostPatterns(host SSHHost) []SSHHost {
+	patterns := strings.Fields(host.Name)
+	if len(patterns) < 2 {
+		host.Pattern = IsPattern(host.Name)
+		return []SSHHost{host}
+	}
+
+	var literals, wildcards, negated []string
+	for _, pattern := range patterns {
+		switch {
+		case strings.HasPrefix(pattern, "!"):
+			negated = append(negated, pattern)
+		case strings.ContainsAny(pattern, "*?"):
+			wildcards = append(wildcards, pattern)
+		default:
+			literals = append(literals, pattern)
+		}
+	}
+
+	var entries []SSHHost
+	add := func(name string, pattern bool) {
+		entry := host
+		entry.Name = name
+		entry.Pattern = pattern
+		entry.Meta = maps.Clone(host.Meta)
+		entry.Profiles = maps.Clone(host.Profiles)
+		entry.Extra = maps.Clone(host.Extra)
+		entries = append(entries, entry)
+	}
+	for _, name := range literals {
+		if len(negated) == 0 || matchesHost(strings.Join(append([]string{name}, negated...), " "), name) {
+			add(name, false)
+		}
+	}
+	if len(wildcards) > 0 {
+		add(strings.Join(append(wildcards, negated...), " "), true)
+	}
+	if len(entries) == 0 {
+		host.Pattern = true
+		return []SSHHost{host}
+	}
+	return entries
+}
+
+// resolveHost computes the effective settings of hostName from all the entries
+// that match it. As in ssh, the first value found for a setting wins.
+func resolveHost(hosts []SSHHost, hostName string) SSHHost {
+	resolved := SSHHost{Name: hostName}
+	first := func(dst *string, value string) {
+		if *dst == "" {
+			*dst = value
+		}
+	}
+
+	for _, host := range hosts {
+		if host.Name != hostName && !matchesHost(host.Name, hostName) {
+			continue
+		}
+		first(&resolved.Hostname, host.Hostname)
+		first(&resolved.User, host.User)
+		// The parser defaults the port to 22, which must not shadow a later match
+		if host.Port != "22" {
+			first(&resolved.Port, host.Port)
+		}
+		first(&resolved.Identity, host.Identity)
+		first(&resolved.ProxyJump, host.ProxyJump)
+		first(&resolved.ProxyCommand, host.ProxyCommand)
+		first(&resolved.ControlMaster, host.ControlMaster)
+		first(&resolved.ControlPath, host.ControlPath)
+		first(&resolved.Ciphers, host.Ciphers)
+		first(&resolved.MACs, host.MACs)
+		first(&resolved.KexAlgorithms, host.KexAlgorithms)
+		first(&resolved.AddressFamily, host.AddressFamily)
+		first(&resolved.BindAddress, host.BindAddress)
+		first(&resolved.ForwardX11, host.ForwardX11)
+		first(&resolved.ForwardX11Trusted, host.ForwardX11Trusted)
+		first(&resolved.ForwardAgent, host.ForwardAgent)
+		first(&resolved.ServerAliveInterval, host.ServerAliveInterval)
+		first(&resolved.StrictHostKeyChecking, host.StrictHostKeyChecking)
+		first(&resolved.ConnectTimeout, host.ConnectTimeout)
+		// Forwards accumulate over all the matching entries
+		resolved.DynamicForwards = append(resolved.DynamicForwards, host.DynamicForwards...)
+		resolved.LocalForwards = append(resolved.LocalForwards, host.LocalForwards...)
+		resolved.RemoteForwards = append(resolved.RemoteForwards, host.RemoteForwards...)
+		if host.Name == hostName {
+			resolved.Comments = host.Comments
+			resolved.Tags = host.Tags
+			resolved.Group = host.Group
+			resolved.Meta = host.Meta
+			resolved.Profiles = host.Profiles
+			resolved.Launcher = host.Launcher
+			resolved.Options = host.Options
+			resolved.Extra = host.Extra
+		}
+	}
+
+	// Identity files accumulate as well, with duplicate spellings listed once
+	resolved.Identities = effectiveIdentities(hosts, hostName)
+
+	if resolved.Hostname == "" {
+		resolved.Hostname = hostName
+	}
+	if resolved.Port == "" {
+		resolved.Port = "22"
+	}
+	return resolved
+}
+
+// isProxied reports whether a resolved host is reached through a ProxyJump or a
+// ProxyCommand, and so can't be resolved or probed from here
+func isProxied(host SSHHost) bool {
+	return (host.ProxyJump != "" && host.ProxyJump != "none") ||
+		(host.ProxyCommand != "" && host.ProxyCommand != "none")
+}
+
+// jumpHosts returns the host part of each hop of a ProxyJump value, which is a
+// comma-separated list of [user@]host[:port] or ssh:// URIs
+func jumpHosts(proxyJump string) []string {
+	if proxyJump == "" || strings.EqualFold(proxyJump, "none") {
+		return nil
+	}
+
+	var hops []string
+	for _, hop := range strings.Split(proxyJump, ",") {
+		hop = strings.TrimPrefix(strings.TrimSpace(hop), "ssh://")
+		if at := strings.LastIndex(hop, "@"); at >= 0 {
+			hop = hop[at+1:]
+		}
+		if strings.HasPrefix(hop, "[") {
+			// Bracketed IPv6 address, optionally followed by a port
+			if end := strings.Index(hop, "]"); end > 0 {
+				hop = hop[1:end]
+			}
+		} else if colon := strings.LastIndex(hop, ":"); colon >= 0 {
+			hop = hop[:colon]
+		}
+		if hop != "" {
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// isDefinedHost reports whether hostName is matched by one of the entries
+func isDefinedHost(hosts []SSHHost, hostName string) bool {
+	for _, host := range hosts {
+		if host.Name == hostName || matchesHost(host.Name, hostName) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpandHostGlob returns the concrete hosts whose name matches the shell-style
+// glob pattern (e.g. "web*"), in config order. The glob selects host entries by
+// name: wildcard entries of the config such as "Host *" are never returned, nor
+// used to decide what matches.
+func ExpandHostGlob(pattern string) ([]SSHHost, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid host pattern '%s': %w", pattern, err)
+	}
+
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []SSHHost
+	for _, host := range hosts {
+		if IsPattern(host.Name) {
+			continue
+		}
+		if ok, _ := path.Match(pattern, host.Name); ok {
+			matches = append(matches, host)
+		}
+	}
+	return matches, nil
+}
+
+// ResolveConnectTarget resolves a host name typed by the user. It returns the
+// host named exactly input if there is one; otherwise the concrete hosts whose
+// name starts with input, then those containing it (case-insensitively), for
+// the caller to ask which one was meant.
+func ResolveConnectTarget(input string) (exact *SSHHost, candidates []SSHHost, err error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var substring []SSHHost
+	needle := strings.ToLower(input)
+	for _, host := range hosts {
+		if IsPattern(host.Name) {
+			continue
+		}
+		if host.Name == input {
+			return &host, nil, nil
+		}
+		name := strings.ToLower(host.Name)
+		switch {
+		case strings.HasPrefix(name, needle):
+			candidates = append(candidates, host)
+		case strings.Contains(name, needle):
+			substring = append(substring, host)
+		}
+	}
+
+	candidates = append(candidates, substring...)
+	if len(candidates) == 0 {
+		return nil, nil, fmt.Errorf("no host matches '%s'", input)
+	}
+	return nil, candidates, nil
+}
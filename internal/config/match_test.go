@@ -0,0 +1,33 @@
+package config
+
+import "testing"
+
+func TestMatchMayApply(t *testing.T) {
+	host := SSHHost{Name: "web", Hostname: "web.example"}
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"Match all", true},
+		{"Match host web", true},
+		{"Match host db", false},
+		{"Match host *.example", true},
+		{"Match host db,web", true},
+		{"Match !host web", false},
+		{"Match originalhost web.example", false},
+		{"Match=host web", true},
+		{"Match = host db", false},
+		{`Match host "db,web"`, true},
+		{`Match exec "test -f /etc/ok" host db`, false},
+		{`Match exec "test -f /etc/ok" host web`, true},
+		{"Match user root host web", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got := matchMayApply(tt.line, host); got != tt.want {
+				t.Errorf("matchMayApply(%q) = %v, want %v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// MetadataStorage selects where the gosshm metadata of hosts (tags, group,
+// meta, profiles, launcher and options) is stored
+type MetadataStorage string
+
+const (
+	// MetadataInComments stores metadata as structured comments above each Host
+	MetadataInComments MetadataStorage = "comment"
+	// MetadataInSidecar stores metadata in the sidecar file, keyed by host name,
+	// which keeps the SSH config free of any gosshm comment
+	MetadataInSidecar MetadataStorage = "sidecar"
+)
+
+// MetadataMode is where host metadata is written. In sidecar mode, structured
+// comments still found in the config are read, and dropped the next time their
+// host is written.
+var MetadataMode = MetadataInComments
+
+// hostMetadata is the metadata of a host as stored in the sidecar
+type hostMetadata struct {
+	Tags     []string          `json:"tags,omitempty"`
+	Group    string            `json:"group,omitempty"`
+	Meta     map[string]string `json:"meta,omitempty"`
+	Profiles map[string]string `json:"profiles,omitempty"`
+	Launcher string            `json:"launcher,omitempty"`
+	Options  []string          `json:"options,omitempty"`
+}
+
+// metadataOf returns the metadata of host, or nil if it has none
+func metadataOf(host SSHHost) *hostMetadata {
+	if len(host.Tags) == 0 && host.Group == "" && len(host.Meta) == 0 && len(host.Profiles) == 0 && host.Launcher == "" && len(host.Options) == 0 {
+		return nil
+	}
+	return &hostMetadata{
+		Tags:     host.Tags,
+		Group:    host.Group,
+		Meta:     host.Meta,
+		Profiles: host.Profiles,
+		Launcher: host.Launcher,
+		Options:  host.Options,
+	}
+}
+
+// applySidecarMetadata sets the metadata stored in the sidecar on the hosts
+// that have some, when metadata is stored in the sidecar
+func applySidecarMetadata(hosts []SSHHost) error {
+	if MetadataMode != MetadataInSidecar {
+		return nil
+	}
+
+	sidecarMutex.Lock()
+	defer sidecarMutex.Unlock()
+
+	data, err := loadSidecar()
+	if err != nil {
+		return err
+	}
+
+	for i := range hosts {
+		md, ok := data.Metadata[hosts[i].Name]
+		if !ok {
+			continue
+		}
+		hosts[i].Tags = md.Tags
+		hosts[i].Group = md.Group
+		hosts[i].Meta = md.Meta
+		hosts[i].Profiles = md.Profiles
+		hosts[i].Launcher = md.Launcher
+		hosts[i].Options = md.Options
+	}
+	return nil
+}
+
+// writeWithSidecarMetadata runs write, which changes the config, and stores
+// the metadata of hosts in the sidecar, forgetting that of the removed host
+// names, when metadata is stored in the sidecar. The sidecar is saved first,
+// and put back should write fail, so that an error leaves both files as they
+// were. A corrupt sidecar is never overwritten: nothing is written at all.
+func writeWithSidecarMetadata(write func() error, hosts []SSHHost, removed ...string) error {
+	if MetadataMode != MetadataInSidecar {
+		return write()
+	}
+
+	sidecarMutex.Lock()
+	defer sidecarMutex.Unlock()
+
+	data, err := readSidecar()
+	if err != nil {
+		return err
+	}
+	path, err := getSidecarPath()
+	if err != nil {
+		return err
+	}
+	previous, err := readFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	existed := err == nil
+
+	if data.Metadata == nil {
+		data.Metadata = make(map[string]*hostMetadata)
+	}
+	for _, name := range removed {
+		delete(data.Metadata, name)
+	}
+	for _, host := range hosts {
+		if md := metadataOf(host); md != nil {
+			data.Metadata[host.Name] = md
+		} else {
+			delete(data.Metadata, host.Name)
+		}
+	}
+	if err := saveSidecar(data); err != nil {
+		return err
+	}
+
+	if err := write(); err != nil {
+		var restoreErr error
+		if existed {
+			restoreErr = atomicWriteFile(path, previous, 0600)
+		} else {
+			restoreErr = Files.Remove(path)
+		}
+		return errors.Join(err, restoreErr)
+	}
+	return nil
+}
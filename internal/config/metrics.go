@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// escapeLabelValue escapes a Prometheus label value
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// writeGaugeHeader writes the help and type lines of a gauge
+func writeGaugeHeader(w io.Writer, name, help string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	return err
+}
+
+// WritePrometheusMetrics writes inventory metrics about the config in the
+// Prometheus text exposition format
+func WritePrometheusMetrics(w io.Writer) error {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	byTag := make(map[string]int)
+	for _, host := range hosts {
+		if IsPattern(host.Name) {
+			continue
+		}
+		total++
+		for _, tag := range host.Tags {
+			byTag[tag]++
+		}
+	}
+
+	backups, err := listBackups(configPath)
+	if err != nil {
+		return err
+	}
+
+	reachability, err := LastReachability()
+	if err != nil {
+		return err
+	}
+	reachable := 0
+	for _, result := range reachability {
+		if result.Reachable {
+			reachable++
+		}
+	}
+
+	if err := writeGaugeHeader(w, "gosshm_hosts_total", "Number of hosts defined in the SSH config."); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "gosshm_hosts_total %d\n", total); err != nil {
+		return err
+	}
+
+	if err := writeGaugeHeader(w, "gosshm_hosts_reachable", "Number of hosts found reachable by the last ping sweep."); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "gosshm_hosts_reachable %d\n", reachable); err != nil {
+		return err
+	}
+
+	if err := writeGaugeHeader(w, "gosshm_hosts_by_tag", "Number of hosts carrying each tag."); err != nil {
+		return err
+	}
+	tags := make([]string, 0, len(byTag))
+	for tag := range byTag {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		if _, err := fmt.Fprintf(w, "gosshm_hosts_by_tag{tag=\"%s\"} %d\n", escapeLabelValue(tag), byTag[tag]); err != nil {
+			return err
+		}
+	}
+
+	if err := writeGaugeHeader(w, "gosshm_backups_total", "Number of backups of the SSH config."); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "gosshm_backups_total %d\n", len(backups))
+	return err
+}
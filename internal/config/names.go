@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"sshm/internal/validation"
+)
+
+// renameJumpHosts rewrites the hops of a ProxyJump value that refer to renamed
+// hosts, keeping their user and port
+func renameJumpHosts(proxyJump string, renamed map[string]string) string {
+	hops := strings.Split(proxyJump, ",")
+	for i, hop := range hops {
+		name := strings.TrimSpace(hop)
+		prefix, suffix := "", ""
+		if strings.HasPrefix(name, "ssh://") {
+			prefix, name = "ssh://", strings.TrimPrefix(name, "ssh://")
+		}
+		if at := strings.LastIndex(name, "@"); at >= 0 {
+			prefix, name = prefix+name[:at+1], name[at+1:]
+		}
+		if !strings.HasPrefix(name, "[") {
+			if colon := strings.LastIndex(name, ":"); colon >= 0 {
+				name, suffix = name[:colon], name[colon:]
+			}
+		}
+		if newName, ok := renamed[name]; ok {
+			hops[i] = prefix + newName + suffix
+		}
+	}
+	return strings.Join(hops, ",")
+}
+
+// NormalizeHostNames renames every concrete host of the config and the files
+// it includes with fn (e.g. strings.ToLower) and updates the ProxyJump
+// references to renamed hosts, in a single backed-up write of each changed
+// file. It returns the old to new name of each renamed host. Nothing is
+// written if a new name is invalid or already used by another host.
+func NormalizeHostNames(fn func(string) string) (renamed map[string]string, err error) {
+	if ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := collectConfigFiles(configPath, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := parseSSHConfigFile(configPath, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	if err := applySidecarMetadata(hosts); err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		existing[host.Name] = true
+	}
+
+	renamed = make(map[string]string)
+	taken := make(map[string]string)
+	for _, host := range hosts {
+		if _, ok := renamed[host.Name]; ok || IsPattern(host.Name) {
+			continue
+		}
+		newName := fn(host.Name)
+		if newName == host.Name {
+			continue
+		}
+		if !validation.ValidateHostName(newName) || IsPattern(newName) {
+			return nil, fmt.Errorf("invalid name '%s' for host '%s'", newName, host.Name)
+		}
+		if existing[newName] {
+			return nil, fmt.Errorf("cannot rename '%s' to '%s': host already exists", host.Name, newName)
+		}
+		if other, ok := taken[newName]; ok {
+			return nil, fmt.Errorf("cannot rename both '%s' and '%s' to '%s'", other, host.Name, newName)
+		}
+		taken[newName] = host.Name
+		renamed[host.Name] = newName
+	}
+	if len(renamed) == 0 {
+		return renamed, nil
+	}
+
+	seen := make(map[string]bool, len(files))
+	var changedFiles []string
+	changed := make(map[string][]string)
+	var written []SSHHost
+	var removed []string
+	for _, file := range files {
+		if seen[file] {
+			continue
+		}
+		seen[file] = true
+		content, err := readConfigFile(file)
+		if err != nil {
+			return nil, err
+		}
+		lines := strings.Split(string(content), "\n")
+		fileChanged := false
+		for _, host := range hosts {
+			if host.SourceFile != file {
+				continue
+			}
+			newHost := host
+			if newName, ok := renamed[host.Name]; ok {
+				newHost.Name = newName
+				removed = append(removed, host.Name)
+			}
+			newHost.ProxyJump = renameJumpHosts(host.ProxyJump, renamed)
+			if newHost.Name == host.Name && newHost.ProxyJump == host.ProxyJump {
+				continue
+			}
+			lines, _ = replaceHostBlock(lines, host.Name, newHost)
+			written = append(written, newHost)
+			fileChanged = true
+		}
+		if fileChanged {
+			changedFiles = append(changedFiles, file)
+			changed[file] = lines
+		}
+	}
+
+	for _, file := range changedFiles {
+		// Create backup before modification
+		if err := backupConfig(file); err != nil {
+			return nil, fmt.Errorf("failed to create backup: %w", err)
+		}
+	}
+
+	if err := writeWithSidecarMetadata(func() error {
+		for _, file := range changedFiles {
+			if err := writeConfigFile(file, []byte(strings.Join(changed[file], "\n"))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, written, removed...); err != nil {
+		return nil, err
+	}
+	return renamed, nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeHostNamesIncludedFile(t *testing.T) {
+	path, extraPath := useTestConfigWithInclude(t,
+		"Host App\n    HostName app.example\n    ProxyJump admin@Bastion:2222\n",
+		"Host Bastion\n    HostName bastion.example\n\nHost DB\n    HostName db.example\n    ProxyJump Bastion\n")
+
+	renamed, err := NormalizeHostNames(strings.ToLower)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"App": "app", "Bastion": "bastion", "DB": "db"}
+	if !reflect.DeepEqual(renamed, want) {
+		t.Errorf("NormalizeHostNames() = %v, want %v", renamed, want)
+	}
+
+	config := readTestFile(t, path)
+	if !strings.Contains(config, "Host app\n") || !strings.Contains(config, "ProxyJump admin@bastion:2222") {
+		t.Errorf("config = %q, want app renamed and its ProxyJump updated", config)
+	}
+	extra := readTestFile(t, extraPath)
+	if !strings.Contains(extra, "Host bastion\n") || !strings.Contains(extra, "Host db\n") ||
+		!strings.Contains(extra, "ProxyJump bastion") {
+		t.Errorf("included file = %q, want its hosts renamed and ProxyJump updated", extra)
+	}
+}
+
+func TestNormalizeHostNamesCollision(t *testing.T) {
+	path, extraPath := useTestConfigWithInclude(t, "Host Web\n    HostName web.example\n",
+		"Host web\n    HostName other.example\n")
+
+	if _, err := NormalizeHostNames(strings.ToLower); err == nil {
+		t.Fatal("NormalizeHostNames() succeeded, want an error for the name taken in the included file")
+	}
+	if config := readTestFile(t, path); !strings.Contains(config, "Host Web\n") {
+		t.Errorf("config = %q, want it unchanged", config)
+	}
+	if extra := readTestFile(t, extraPath); extra != "Host web\n    HostName other.example\n" {
+		t.Errorf("included file = %q, want it unchanged", extra)
+	}
+}
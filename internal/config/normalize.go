@@ -0,0 +1,297 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// NormalizeOnWrite makes every write of a config file also heal it: the file
+// ends with exactly one newline, NUL bytes are stripped and the content is
+// converted to UTF-8. Disabled by default so files are written as they are.
+var NormalizeOnWrite bool
+
+// NormalizeReport describes what normalizing a config file changed
+type NormalizeReport struct {
+	Path            string
+	Encoding        string // Original encoding when converted to UTF-8, empty otherwise
+	RemovedBOM      bool
+	RemovedNULs     int
+	AddedNewline    bool
+	TrimmedNewlines int // Extra trailing newlines removed
+}
+
+// Changed reports whether normalization modified the content
+func (r NormalizeReport) Changed() bool {
+	return r.Encoding != "" || r.RemovedBOM || r.RemovedNULs > 0 || r.AddedNewline || r.TrimmedNewlines > 0
+}
+
+// String returns a one line summary of the changes
+func (r NormalizeReport) String() string {
+	var changes []string
+	if r.Encoding != "" {
+		changes = append(changes, fmt.Sprintf("converted from %s to UTF-8", r.Encoding))
+	}
+	if r.RemovedBOM {
+		changes = append(changes, "removed byte order mark")
+	}
+	if r.RemovedNULs > 0 {
+		changes = append(changes, fmt.Sprintf("removed %d NUL byte(s)", r.RemovedNULs))
+	}
+	if r.AddedNewline {
+		changes = append(changes, "added final newline")
+	}
+	if r.TrimmedNewlines > 0 {
+		changes = append(changes, fmt.Sprintf("removed %d extra trailing newline(s)", r.TrimmedNewlines))
+	}
+	if len(changes) == 0 {
+		return r.Path + ": no changes"
+	}
+	return r.Path + ": " + strings.Join(changes, ", ")
+}
+
+var (
+	// normalizeMutex protects lastNormalization
+	normalizeMutex    sync.Mutex
+	lastNormalization []NormalizeReport
+)
+
+// utf16Encoding returns the encoding announced by the UTF-16 byte order mark
+// content starts with, empty when there is none
+func utf16Encoding(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, []byte{0xFF, 0xFE}):
+		return "UTF-16LE"
+	case bytes.HasPrefix(content, []byte{0xFE, 0xFF}):
+		return "UTF-16BE"
+	}
+	return ""
+}
+
+// decodeUTF16 converts UTF-16 content without its byte order mark to UTF-8
+func decodeUTF16(content []byte, bigEndian bool) []byte {
+	units := make([]uint16, len(content)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(content[2*i])<<8 | uint16(content[2*i+1])
+		} else {
+			units[i] = uint16(content[2*i+1])<<8 | uint16(content[2*i])
+		}
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+// encodeUTF16 converts UTF-8 content to UTF-16, with a byte order mark when bom
+// is set
+func encodeUTF16(content []byte, bigEndian, bom bool) []byte {
+	runes := []rune(string(content))
+	if bom {
+		runes = append([]rune{'\uFEFF'}, runes...)
+	}
+	dst := make([]byte, 0, 2*len(runes))
+	for _, unit := range utf16.Encode(runes) {
+		if bigEndian {
+			dst = append(dst, byte(unit>>8), byte(unit))
+		} else {
+			dst = append(dst, byte(unit), byte(unit>>8))
+		}
+	}
+	return dst
+}
+
+// utf16Writer encodes the UTF-8 written to it as UTF-16, after a byte order
+// mark, holding back a rune split between two writes until it is complete
+type utf16Writer struct {
+	w         io.Writer
+	bigEndian bool
+	started   bool
+	pending   []byte
+}
+
+func (u *utf16Writer) Write(p []byte) (int, error) {
+	data := append(u.pending, p...)
+	end := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+	u.pending = append([]byte(nil), data[end:]...)
+
+	if _, err := u.w.Write(encodeUTF16(data[:end], u.bigEndian, !u.started)); err != nil {
+		return 0, err
+	}
+	u.started = true
+	return len(p), nil
+}
+
+// openConfigFile opens a config file for reading as UTF-8, decoding it when it
+// is UTF-16, and returns its UTF-16 encoding, if any, for the writers to keep
+func openConfigFile(name string) (io.ReadCloser, string, error) {
+	file, err := Files.Open(name)
+	if err != nil {
+		return nil, "", err
+	}
+	r := bufio.NewReader(file)
+	bom, _ := r.Peek(2)
+	encoding := utf16Encoding(bom)
+	if encoding == "" {
+		return struct {
+			io.Reader
+			io.Closer
+		}{r, file}, "", nil
+	}
+
+	defer file.Close()
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, "", err
+	}
+	content = decodeUTF16(content[2:], encoding == "UTF-16BE")
+	return io.NopCloser(bytes.NewReader(content)), encoding, nil
+}
+
+// readConfigFile returns the content of a config file as UTF-8, as read by
+// openConfigFile
+func readConfigFile(name string) ([]byte, error) {
+	file, _, err := openConfigFile(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// configFileEncoding returns the UTF-16 encoding of the file at path, empty
+// when it isn't UTF-16 or doesn't exist
+func configFileEncoding(path string) string {
+	file, err := Files.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	bom := make([]byte, 2)
+	n, _ := io.ReadFull(file, bom)
+	return utf16Encoding(bom[:n])
+}
+
+// decodeLatin1 converts content that isn't valid UTF-8 to UTF-8, reading each
+// byte as a Latin-1 character, which is what such files almost always hold
+func decodeLatin1(content []byte) []byte {
+	var buf bytes.Buffer
+	for _, b := range content {
+		buf.WriteRune(rune(b))
+	}
+	return buf.Bytes()
+}
+
+// normalizeContent returns the normalized content and what was changed
+func normalizeContent(content []byte) ([]byte, NormalizeReport) {
+	var report NormalizeReport
+
+	switch encoding := utf16Encoding(content); {
+	case encoding != "":
+		content = decodeUTF16(content[2:], encoding == "UTF-16BE")
+		report.Encoding = encoding
+	case bytes.HasPrefix(content, []byte{0xEF, 0xBB, 0xBF}):
+		content = content[3:]
+		report.RemovedBOM = true
+	}
+
+	if n := bytes.Count(content, []byte{0}); n > 0 {
+		content = bytes.ReplaceAll(content, []byte{0}, nil)
+		report.RemovedNULs = n
+	}
+
+	if !utf8.Valid(content) {
+		content = decodeLatin1(content)
+		report.Encoding = "Latin-1"
+	}
+
+	if len(content) > 0 {
+		trimmed := bytes.TrimRight(content, "\n")
+		switch extra := len(content) - len(trimmed); {
+		case extra == 0:
+			report.AddedNewline = true
+		case extra > 1:
+			report.TrimmedNewlines = extra - 1
+		}
+		content = append(trimmed, '\n')
+	}
+
+	return content, report
+}
+
+// writeConfigFile atomically writes a config file, normalizing it first when
+// NormalizeOnWrite is enabled. The writers edit config files as UTF-8, as read
+// by readConfigFile; a UTF-16 file is encoded back unless normalized.
+func writeConfigFile(path string, content []byte) error {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+
+	encoding := configFileEncoding(path)
+	if NormalizeOnWrite {
+		var report NormalizeReport
+		content, report = normalizeContent(content)
+		if report.Encoding == "" {
+			report.Encoding = encoding
+		}
+		if report.Changed() {
+			report.Path = path
+			normalizeMutex.Lock()
+			lastNormalization = append(lastNormalization, report)
+			normalizeMutex.Unlock()
+		}
+	} else if encoding != "" && utf16Encoding(content) == "" {
+		content = encodeUTF16(content, encoding == "UTF-16BE", true)
+	}
+	return atomicWriteFile(path, content, 0600)
+}
+
+// LastNormalization returns the changes made by NormalizeOnWrite since the
+// previous call, one report per modified write
+func LastNormalization() []NormalizeReport {
+	normalizeMutex.Lock()
+	defer normalizeMutex.Unlock()
+
+	reports := lastNormalization
+	lastNormalization = nil
+	return reports
+}
+
+// CheckNormalization reports what normalizing each config file (the main one
+// and the included ones) would change, without modifying anything
+func CheckNormalization() ([]NormalizeReport, error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := collectConfigFiles(configPath, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []NormalizeReport
+	for _, file := range files {
+		content, err := readFile(file)
+		if err != nil {
+			return nil, err
+		}
+		_, report := normalizeContent(content)
+		if report.Changed() {
+			report.Path = file
+			reports = append(reports, report)
+		}
+	}
+	return reports, nil
+}
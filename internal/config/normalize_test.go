@@ -0,0 +1,81 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// useUTF16Config writes content as the config, encoded in UTF-16
+func useUTF16Config(t *testing.T, content string, bigEndian bool) string {
+	t.Helper()
+	path := useTestConfig(t, "")
+	if err := os.WriteFile(path, encodeUTF16([]byte(content), bigEndian, true), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestWriteUTF16Config(t *testing.T) {
+	for _, bigEndian := range []bool{false, true} {
+		path := useUTF16Config(t, "Host café\n    HostName 10.0.0.1\n", bigEndian)
+
+		if err := AddSSHHost(SSHHost{Name: "db", Hostname: "10.0.0.2"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := UpdateSSHHostStreaming("café", SSHHost{Name: "café", Hostname: "10.0.0.3", User: "zoë"}); err != nil {
+			t.Fatal(err)
+		}
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := utf16Encoding(raw); got == "" || (got == "UTF-16BE") != bigEndian {
+			t.Fatalf("config encoding = %q, want UTF-16 with bigEndian %v", got, bigEndian)
+		}
+		content := string(decodeUTF16(raw[2:], bigEndian))
+		for _, want := range []string{"Host café\n", "HostName 10.0.0.3", "User zoë", "Host db\n", "HostName 10.0.0.2"} {
+			if !strings.Contains(content, want) {
+				t.Errorf("config = %q, want it to contain %q", content, want)
+			}
+		}
+		if host, err := GetSSHHost("db"); err != nil || host.Hostname != "10.0.0.2" {
+			t.Errorf("GetSSHHost(db) = %+v, %v", host, err)
+		}
+	}
+}
+
+func TestNormalizeUTF16OnWrite(t *testing.T) {
+	path := useUTF16Config(t, "Host web\r\n    HostName 10.0.0.1\n", false)
+	setForTest(t, &NormalizeOnWrite, true)
+	LastNormalization()
+
+	if err := AddSSHHost(SSHHost{Name: "db", Hostname: "10.0.0.2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	content := readTestFile(t, path)
+	if !strings.HasPrefix(content, "Host web") || !strings.Contains(content, "Host db\n    HostName 10.0.0.2\n") {
+		t.Errorf("config = %q, want both hosts in UTF-8", content)
+	}
+	reports := LastNormalization()
+	if len(reports) != 1 || reports[0].Encoding != "UTF-16LE" {
+		t.Errorf("LastNormalization() = %+v, want a conversion from UTF-16LE", reports)
+	}
+}
+
+func TestUTF16WriterSplitRunes(t *testing.T) {
+	const text = "Host café\n    User zoë 🙂\n"
+	var buf bytes.Buffer
+	w := &utf16Writer{w: &buf}
+	for i := 0; i < len(text); i++ {
+		if _, err := w.Write([]byte{text[i]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := buf.Bytes(); !bytes.Equal(got, encodeUTF16([]byte(text), false, true)) {
+		t.Errorf("utf16Writer wrote %q, want %q", decodeUTF16(got[2:], false), text)
+	}
+}
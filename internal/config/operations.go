@@ -0,0 +1,177 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Operation kinds of an Operation
+const (
+	OpAdd    = "add"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// RecordOperationsTo is the path of a file to which every successful add, update
+// and delete of a host is appended as a JSON line, to be replayed elsewhere with
+// ReplayOperations. Recording is disabled when empty.
+var RecordOperationsTo string
+
+// Operation is a recorded change of the config
+type Operation struct {
+	Op   string    `json:"op"`
+	Name string    `json:"name"` // Host changed, its old name for a rename
+	Host *SSHHost  `json:"host,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// recordOperation appends an operation on hostName to the recording file, if
+// recording is enabled. The location of host is not recorded, since it is
+// meaningless on another machine.
+func recordOperation(op, hostName string, host *SSHHost) error {
+	if RecordOperationsTo == "" {
+		return nil
+	}
+
+	if host != nil {
+		recorded := *host
+		recorded.SourceFile, recorded.LineNumber, recorded.EndLine = "", 0, 0
+		host = &recorded
+	}
+	line, err := json.Marshal(Operation{Op: op, Name: hostName, Host: host, Time: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	file, err := Files.Append(RecordOperationsTo, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// upsertSSHHost updates the host named host.Name, or else renames the host
+// named from, or else adds host
+func upsertSSHHost(host SSHHost, from string) error {
+	for _, name := range []string{host.Name, from} {
+		if name == "" {
+			continue
+		}
+		exists, err := HostExists(name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return UpdateSSHHost(name, host)
+		}
+	}
+	return AddSSHHost(host)
+}
+
+// netChange is the final state of a host after a sequence of operations
+type netChange struct {
+	host *SSHHost // nil when deleted
+	from string   // Name the host had before the operations, if renamed
+}
+
+// foldOperations reduces operations to the final state of each host they touch,
+// in the order the hosts first appear
+func foldOperations(ops []Operation) ([]string, map[string]*netChange, error) {
+	var order []string
+	changes := make(map[string]*netChange)
+	set := func(name string, change netChange) {
+		if _, ok := changes[name]; !ok {
+			order = append(order, name)
+		}
+		changes[name] = &change
+	}
+
+	for i, op := range ops {
+		switch op.Op {
+		case OpAdd, OpUpdate:
+			if op.Host == nil {
+				return nil, nil, fmt.Errorf("operation %d: %s of '%s' has no host", i+1, op.Op, op.Name)
+			}
+			from := ""
+			if op.Op == OpUpdate && op.Name != op.Host.Name {
+				from = op.Name
+				if prev, ok := changes[op.Name]; ok && prev.from != "" {
+					from = prev.from
+				}
+				set(op.Name, netChange{})
+			} else if prev, ok := changes[op.Host.Name]; ok {
+				from = prev.from
+			}
+			set(op.Host.Name, netChange{host: op.Host, from: from})
+		case OpDelete:
+			set(op.Name, netChange{})
+		default:
+			return nil, nil, fmt.Errorf("operation %d: unknown operation '%s'", i+1, op.Op)
+		}
+	}
+	return order, changes, nil
+}
+
+// ReplayOperations applies the operations recorded in path to the config. The
+// operations are first reduced to the final state of each host, which is then
+// applied with upserts (renaming hosts in place when possible) and deletes of
+// the hosts that still exist, so that replaying is idempotent.
+func ReplayOperations(path string) error {
+	file, err := Files.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var ops []Operation
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var op Operation
+		if err := json.Unmarshal(scanner.Bytes(), &op); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		ops = append(ops, op)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	order, changes, err := foldOperations(ops)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range order {
+		if change := changes[name]; change.host != nil {
+			if err := upsertSSHHost(*change.host, change.from); err != nil {
+				return fmt.Errorf("replaying '%s': %w", name, err)
+			}
+		}
+	}
+	for _, name := range order {
+		if changes[name].host != nil {
+			continue
+		}
+		exists, err := HostExists(name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			if err := DeleteSSHHost(name); err != nil {
+				return fmt.Errorf("replaying '%s': %w", name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package config
+
+// HostPatch is a change to some settings of a host: nil fields are left as they
+// are, set ones replace the current value, an empty value clearing it. The
+// name is changed with RenameSSHHost.
+type HostPatch struct {
+	Hostname              *string
+	User                  *string
+	Port                  *string
+	Identities            *[]string
+	ProxyJump             *string
+	ProxyCommand          *string
+	ControlMaster         *string
+	ControlPath           *string
+	Ciphers               *string
+	MACs                  *string
+	KexAlgorithms         *string
+	AddressFamily         *string
+	BindAddress           *string
+	ForwardX11            *string
+	ForwardX11Trusted     *string
+	DynamicForwards       *[]string
+	LocalForwards         *[]string
+	RemoteForwards        *[]string
+	ForwardAgent          *string
+	ServerAliveInterval   *string
+	StrictHostKeyChecking *string
+	ConnectTimeout        *string
+	Tags                  *[]string
+	Group                 *string
+	Meta                  *map[string]string
+	Profiles              *map[string]string
+	Launcher              *string
+	Options               *[]string
+}
+
+// patchField sets *dst to *value when value is not nil
+func patchField[T any](dst *T, value *T) {
+	if value != nil {
+		*dst = *value
+	}
+}
+
+// apply changes host as described by p
+func (p HostPatch) apply(host *SSHHost) {
+	patchField(&host.Hostname, p.Hostname)
+	patchField(&host.User, p.User)
+	patchField(&host.Port, p.Port)
+	if p.Identities != nil {
+		host.SetIdentities(*p.Identities...)
+	}
+	patchField(&host.ProxyJump, p.ProxyJump)
+	patchField(&host.ProxyCommand, p.ProxyCommand)
+	patchField(&host.ControlMaster, p.ControlMaster)
+	patchField(&host.ControlPath, p.ControlPath)
+	patchField(&host.Ciphers, p.Ciphers)
+	patchField(&host.MACs, p.MACs)
+	patchField(&host.KexAlgorithms, p.KexAlgorithms)
+	patchField(&host.AddressFamily, p.AddressFamily)
+	patchField(&host.BindAddress, p.BindAddress)
+	patchField(&host.ForwardX11, p.ForwardX11)
+	patchField(&host.ForwardX11Trusted, p.ForwardX11Trusted)
+	patchField(&host.DynamicForwards, p.DynamicForwards)
+	patchField(&host.LocalForwards, p.LocalForwards)
+	patchField(&host.RemoteForwards, p.RemoteForwards)
+	patchField(&host.ForwardAgent, p.ForwardAgent)
+	patchField(&host.ServerAliveInterval, p.ServerAliveInterval)
+	patchField(&host.StrictHostKeyChecking, p.StrictHostKeyChecking)
+	patchField(&host.ConnectTimeout, p.ConnectTimeout)
+	patchField(&host.Tags, p.Tags)
+	patchField(&host.Group, p.Group)
+	patchField(&host.Meta, p.Meta)
+	patchField(&host.Profiles, p.Profiles)
+	patchField(&host.Launcher, p.Launcher)
+	patchField(&host.Options, p.Options)
+}
+
+// PatchSSHHost applies patch to the named host, leaving the settings it does
+// not set untouched, unlike UpdateSSHHost which writes the host as given. The
+// host is read and written under one lock, so that a concurrent edit is not
+// overwritten.
+func PatchSSHHost(name string, patch HostPatch) (err error) {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+
+	var patched *SSHHost
+	defer func() {
+		if err == nil {
+			err = recordOperation(OpUpdate, name, patched)
+		}
+	}()
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	host, err := getSSHHost(name)
+	if err != nil {
+		return err
+	}
+	patch.apply(host)
+	if host.Tags, err = normalizeTags(host.Tags); err != nil {
+		return err
+	}
+	if err := updateSSHHost(name, *host); err != nil {
+		return err
+	}
+	patched = host
+	return nil
+}
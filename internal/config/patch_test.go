@@ -0,0 +1,116 @@
+package config
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+const patchConfig = `# Tags: web
+Host web
+    HostName web.example
+    User deploy
+    Port 2222
+    IdentityFile ~/.ssh/id_web
+    Compression yes
+`
+
+func TestPatchSSHHost(t *testing.T) {
+	user, empty := "root", ""
+	tags := []string{"web", "prod"}
+
+	tests := []struct {
+		name    string
+		patch   HostPatch
+		want    func(*SSHHost)
+		wantErr bool
+	}{
+		{
+			name:  "empty patch",
+			patch: HostPatch{},
+			want:  func(*SSHHost) {},
+		},
+		{
+			name:  "one field",
+			patch: HostPatch{User: &user},
+			want:  func(h *SSHHost) { h.User = "root" },
+		},
+		{
+			name:  "cleared field",
+			patch: HostPatch{Identities: &[]string{}},
+			want:  func(h *SSHHost) { h.SetIdentities() },
+		},
+		{
+			name:  "cleared port",
+			patch: HostPatch{Port: &empty},
+			want:  func(h *SSHHost) { h.Port = "22" },
+		},
+		{
+			name:  "tags",
+			patch: HostPatch{Tags: &tags},
+			want:  func(h *SSHHost) { h.Tags = tags },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, patchConfig)
+			want := hostNamed(t, mustParse(t, patchConfig), "web")
+			tt.want(&want)
+
+			if err := PatchSSHHost("web", tt.patch); err != nil {
+				t.Fatal(err)
+			}
+			got, err := GetSSHHost("web")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got.SourceFile, got.EndLine, want.EndLine = "", 0, 0
+			if !reflect.DeepEqual(*got, want) {
+				t.Errorf("patched host = %+v, want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestPatchSSHHostMissing(t *testing.T) {
+	useTestConfig(t, patchConfig)
+	user := "root"
+	if err := PatchSSHHost("db", HostPatch{User: &user}); err == nil {
+		t.Error("PatchSSHHost() of a missing host succeeded")
+	}
+}
+
+func TestPatchSSHHostConcurrent(t *testing.T) {
+	useTestConfig(t, patchConfig)
+	values := []string{"bastion", "auto", "/tmp/%C", "inet", "10.0.0.1", "yes"}
+	patches := []HostPatch{
+		{ProxyJump: &values[0]},
+		{ControlMaster: &values[1]},
+		{ControlPath: &values[2]},
+		{AddressFamily: &values[3]},
+		{BindAddress: &values[4]},
+		{ForwardAgent: &values[5]},
+	}
+
+	var wg sync.WaitGroup
+	for _, patch := range patches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := PatchSSHHost("web", patch); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	host, err := GetSSHHost("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{host.ProxyJump, host.ControlMaster, host.ControlPath, host.AddressFamily, host.BindAddress, host.ForwardAgent}
+	if !reflect.DeepEqual(got, values) {
+		t.Errorf("settings after concurrent patches = %q, want %q", got, values)
+	}
+}
@@ -0,0 +1,285 @@
+package config
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pingConcurrency bounds the number of hosts probed at the same time
+const pingConcurrency = 16
+
+// defaultSSHPorts are the ports SuggestCorrectPort tries when given none
+var defaultSSHPorts = []int{22, 2222, 2200, 22222, 8022}
+
+// ReachResult is the outcome of probing a host. Reachable means something
+// accepted the connection on the port; SSH means it answered with an SSH banner.
+type ReachResult struct {
+	Reachable bool          `json:"reachable"`
+	SSH       bool          `json:"ssh"`
+	Banner    string        `json:"banner,omitempty"`
+	Latency   time.Duration `json:"latency"`
+	Error     string        `json:"error,omitempty"`
+	CheckedAt time.Time     `json:"checked_at"`
+
+	err error // Why the connection could not be opened
+}
+
+// dialNetwork returns the network to dial for an AddressFamily value
+func dialNetwork(addressFamily string) string {
+	switch strings.ToLower(addressFamily) {
+	case "inet":
+		return "tcp4"
+	case "inet6":
+		return "tcp6"
+	}
+	return "tcp"
+}
+
+// probeAddress opens a TCP connection to address over network ("tcp", "tcp4"
+// or "tcp6") and reads the SSH banner the server sends first. Both have to
+// fit in timeout.
+func probeAddress(ctx context.Context, network, address string, timeout time.Duration) ReachResult {
+	start := time.Now()
+	deadline := start.Add(timeout)
+	dialer := net.Dialer{Deadline: deadline}
+
+	conn, err := dialer.DialContext(ctx, network, address)
+	result := ReachResult{CheckedAt: start, Latency: time.Since(start)}
+	if err != nil {
+		result.Error = err.Error()
+		result.err = err
+		return result
+	}
+	defer conn.Close()
+	result.Reachable = true
+
+	conn.SetReadDeadline(deadline)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if strings.HasPrefix(line, "SSH-") {
+		result.SSH = true
+		result.Banner = strings.TrimSpace(line)
+	} else if err != nil {
+		result.Error = "no SSH banner: " + err.Error()
+	} else {
+		result.Error = "not an SSH server"
+	}
+	return result
+}
+
+// probeHost probes the resolved address of host, over its AddressFamily. The
+// name is used when the HostName is empty.
+func probeHost(ctx context.Context, host SSHHost, timeout time.Duration) ReachResult {
+	hostname := host.Hostname
+	if hostname == "" {
+		hostname = host.Name
+	}
+	return probeAddress(ctx, dialNetwork(host.AddressFamily), net.JoinHostPort(hostname, effectivePort(host)), timeout)
+}
+
+// probeHosts probes hosts with probeHost, at most concurrency at a time, and
+// returns the results by host name. Once ctx is done, the hosts not yet probed
+// get its error as result.
+func probeHosts(ctx context.Context, hosts []SSHHost, concurrency int, timeout time.Duration) map[string]ReachResult {
+	results := make(map[string]ReachResult, len(hosts))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for _, host := range hosts {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			mu.Lock()
+			results[host.Name] = ReachResult{Error: ctx.Err().Error(), CheckedAt: time.Now(), err: ctx.Err()}
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func(host SSHHost) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := probeHost(ctx, host, timeout)
+			mu.Lock()
+			results[host.Name] = result
+			mu.Unlock()
+		}(host)
+	}
+	wg.Wait()
+	return results
+}
+
+// Reachable reports whether a TCP connection to h can be opened within timeout,
+// and how long opening it took. The HostName (the name when empty), Port and
+// AddressFamily of h are used as is; a ProxyJump or ProxyCommand is not.
+func (h SSHHost) Reachable(ctx context.Context, timeout time.Duration) (bool, time.Duration) {
+	result := probeHost(ctx, h, timeout)
+	return result.Reachable, result.Latency
+}
+
+// ScanResult is the outcome of checking a host with ScanHosts. Err tells why
+// the host is not Reachable.
+type ScanResult struct {
+	Reachable bool
+	Latency   time.Duration
+	Err       error
+}
+
+// ScanHosts checks hosts as Reachable does, at most concurrency at a time
+// (pingConcurrency when not positive), and returns the results by host name.
+// Once ctx is done, the hosts not yet checked get its error as result.
+func ScanHosts(ctx context.Context, hosts []SSHHost, concurrency int, timeout time.Duration) map[string]ScanResult {
+	if concurrency <= 0 {
+		concurrency = pingConcurrency
+	}
+
+	results := make(map[string]ScanResult, len(hosts))
+	for name, result := range probeHosts(ctx, hosts, concurrency, timeout) {
+		results[name] = ScanResult{Reachable: result.Reachable, Latency: result.Latency, Err: result.err}
+	}
+	return results
+}
+
+// TestConnection probes the named host at its resolved HostName and Port,
+// telling apart a port where nothing listens (not Reachable), a port answered by
+// something else (Reachable but not SSH) and an SSH server (SSH)
+func TestConnection(hostName string, timeout time.Duration) (ReachResult, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return ReachResult{}, err
+	}
+	return probeHost(context.Background(), resolveHost(hosts, hostName), timeout), nil
+}
+
+// SuggestCorrectPort probes the HostName of host on each candidate port (common
+// SSH ports if none are given) and returns the first one where an SSH server
+// answers, to fix a Port that no longer matches the server
+func SuggestCorrectPort(host SSHHost, candidatePorts []int, timeout time.Duration) (int, error) {
+	if len(candidatePorts) == 0 {
+		candidatePorts = defaultSSHPorts
+	}
+	hostname := host.Hostname
+	if hostname == "" {
+		hostname = host.Name
+	}
+
+	for _, port := range candidatePorts {
+		address := net.JoinHostPort(hostname, strconv.Itoa(port))
+		if probeAddress(context.Background(), dialNetwork(host.AddressFamily), address, timeout).SSH {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no SSH server found on %s", hostname)
+}
+
+// PingAllHosts probes every concrete host of the config with a TCP connection
+// to its resolved HostName and Port, and records the results in the sidecar.
+// Hosts reached through a ProxyJump or ProxyCommand cannot be probed directly
+// and are skipped.
+// In read-only mode the results are returned without being recorded.
+func PingAllHosts(ctx context.Context, timeout time.Duration) (map[string]ReachResult, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []SSHHost
+	for _, host := range hosts {
+		if IsPattern(host.Name) {
+			continue
+		}
+		resolved := resolveHost(hosts, host.Name)
+		if isProxied(resolved) {
+			continue
+		}
+		targets = append(targets, resolved)
+	}
+
+	results := probeHosts(ctx, targets, pingConcurrency, timeout)
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+	if ReadOnly {
+		return results, nil
+	}
+
+	sidecarMutex.Lock()
+	defer sidecarMutex.Unlock()
+
+	data, err := loadSidecar()
+	if err != nil {
+		return results, err
+	}
+	data.Reachability = results
+	return results, saveSidecar(data)
+}
+
+// LastReachability returns the results of the last PingAllHosts sweep, so that
+// they can be displayed without probing the hosts again
+func LastReachability() (map[string]ReachResult, error) {
+	sidecarMutex.Lock()
+	defer sidecarMutex.Unlock()
+
+	data, err := loadSidecar()
+	if err != nil {
+		return nil, err
+	}
+	if data.Reachability == nil {
+		return make(map[string]ReachResult), nil
+	}
+	return data.Reachability, nil
+}
+
+// HostConnectivity is the outcome of probing one host in a ConnectivityReport.
+// Latency is the time taken to open the TCP connection.
+type HostConnectivity struct {
+	Host      string
+	Reachable bool
+	Latency   time.Duration
+	Error     string
+}
+
+// SortByLatency orders a report from the fastest reachable host to the slowest,
+// followed by the unreachable hosts in name order
+func SortByLatency(report []HostConnectivity) {
+	sort.SliceStable(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if a.Reachable != b.Reachable {
+			return a.Reachable
+		}
+		if a.Reachable && a.Latency != b.Latency {
+			return a.Latency < b.Latency
+		}
+		return a.Host < b.Host
+	})
+}
+
+// ConnectivityReport probes every host as PingAllHosts does, recording the
+// results likewise, and returns them sorted with SortByLatency, e.g. to pick
+// the fastest bastion
+func ConnectivityReport(ctx context.Context, timeout time.Duration) ([]HostConnectivity, error) {
+	results, err := PingAllHosts(ctx, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	report := make([]HostConnectivity, 0, len(results))
+	for name, result := range results {
+		report = append(report, HostConnectivity{
+			Host:      name,
+			Reachable: result.Reachable,
+			Latency:   result.Latency,
+			Error:     result.Error,
+		})
+	}
+	SortByLatency(report)
+	return report, nil
+}
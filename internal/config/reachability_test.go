@@ -0,0 +1,138 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// listenSSH starts a TCP server answering with an SSH banner and returns its
+// port
+func listenSSH(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+			conn.Close()
+		}
+	}()
+	return strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+}
+
+// listenSilent starts a TCP server that accepts connections but never writes,
+// and returns its port
+func listenSilent(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	return strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+}
+
+// closedPort returns a local port nothing listens on
+func closedPort(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	ln.Close()
+	return port
+}
+
+func TestScanHosts(t *testing.T) {
+	hosts := []SSHHost{
+		{Name: "up", Hostname: "127.0.0.1", Port: listenSSH(t)},
+		{Name: "silent", Hostname: "127.0.0.1", Port: listenSilent(t)},
+		{Name: "down", Hostname: "127.0.0.1", Port: closedPort(t)},
+	}
+
+	results := ScanHosts(context.Background(), hosts, 1, 200*time.Millisecond)
+	if len(results) != len(hosts) {
+		t.Fatalf("ScanHosts() returned %d results, want %d", len(results), len(hosts))
+	}
+	for _, name := range []string{"up", "silent"} {
+		if result := results[name]; !result.Reachable || result.Err != nil {
+			t.Errorf("%s = %+v, want reachable without error", name, result)
+		}
+	}
+	var opErr *net.OpError
+	if down := results["down"]; down.Reachable || !errors.As(down.Err, &opErr) {
+		t.Errorf("down = %+v, want unreachable with the dial error", down)
+	}
+}
+
+func TestScanHostsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := ScanHosts(ctx, []SSHHost{{Name: "a", Hostname: "127.0.0.1", Port: closedPort(t)}}, 0, time.Second)
+	if a := results["a"]; a.Reachable || !errors.Is(a.Err, context.Canceled) {
+		t.Errorf("a = %+v, want the context error", a)
+	}
+}
+
+func TestReachable(t *testing.T) {
+	port := listenSSH(t)
+	tests := []struct {
+		host SSHHost
+		want bool
+	}{
+		{SSHHost{Name: "127.0.0.1", Port: port}, true},
+		{SSHHost{Name: "down", Hostname: "127.0.0.1", Port: closedPort(t)}, false},
+	}
+	for _, tt := range tests {
+		if got, _ := tt.host.Reachable(context.Background(), time.Second); got != tt.want {
+			t.Errorf("%s.Reachable() = %v, want %v", tt.host.Name, got, tt.want)
+		}
+	}
+}
+
+func TestProbeTimeout(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+	host := SSHHost{Name: "silent", Hostname: "127.0.0.1", Port: listenSilent(t)}
+
+	// The banner is waited for within the timeout of the whole check
+	start := time.Now()
+	result := probeHost(context.Background(), host, timeout)
+	if elapsed := time.Since(start); elapsed > timeout+100*time.Millisecond {
+		t.Errorf("probeHost() took %v, want at most %v", elapsed, timeout)
+	}
+	if !result.Reachable || result.SSH || result.Error == "" {
+		t.Errorf("probeHost() = %+v, want reachable without SSH banner", result)
+	}
+
+	start = time.Now()
+	if ok, _ := host.Reachable(context.Background(), timeout); !ok {
+		t.Error("Reachable() = false, want true")
+	}
+	if elapsed := time.Since(start); elapsed > timeout+100*time.Millisecond {
+		t.Errorf("Reachable() took %v, want at most %v", elapsed, timeout)
+	}
+}
@@ -0,0 +1,307 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+const (
+	// MetaManagedKey is the Meta key marking a host as managed by gosshm
+	MetaManagedKey = "managed"
+	// MetaManagedValue is the value of MetaManagedKey for gosshm-managed hosts
+	MetaManagedValue = "gosshm"
+)
+
+// ReconcileResult lists the hosts added, updated and deleted by a reconcile pass
+type ReconcileResult struct {
+	Added   []string
+	Updated []string
+	Deleted []string
+	Changes map[string][]HostChange // Field-level changes of the updated hosts
+}
+
+// IsManaged reports whether the host carries the gosshm managed label
+func (h SSHHost) IsManaged() bool {
+	return h.Meta[MetaManagedKey] == MetaManagedValue
+}
+
+// markManaged returns a copy of host carrying the gosshm managed label
+func markManaged(host SSHHost) SSHHost {
+	meta := make(map[string]string, len(host.Meta)+1)
+	for k, v := range host.Meta {
+		meta[k] = v
+	}
+	meta[MetaManagedKey] = MetaManagedValue
+	host.Meta = meta
+	return host
+}
+
+// effectivePort returns the port of a host, defaulting to 22
+func effectivePort(host SSHHost) string {
+	if host.Port == "" {
+		return "22"
+	}
+	return host.Port
+}
+
+// Reconcile makes the config match the desired hosts: missing hosts are added,
+// hosts whose settings differ are updated, and, when pruneExtra is set, managed
+// hosts absent from desired are deleted. Hosts without the managed label are
+// never pruned. Hosts are updated in the file, main or included, that defines
+// them; new hosts go to ManagedFile when it is set. The changes of each file
+// are applied in a single backed-up write.
+func Reconcile(desired []SSHHost, pruneExtra bool) (result ReconcileResult, err error) {
+	if ReadOnly {
+		return ReconcileResult{}, ErrReadOnly
+	}
+	defer func() {
+		if err == nil {
+			err = recordReconcile(result, desired)
+		}
+	}()
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return ReconcileResult{}, err
+	}
+	paths, targets, err := reconcileTargets(configPath, desired)
+	if err != nil {
+		return ReconcileResult{}, err
+	}
+
+	for _, path := range paths {
+		fileResult, err := reconcileFile(path, targets[path], pruneExtra)
+		result.merge(fileResult)
+		if err != nil {
+			return result, err
+		}
+		if path != configPath && len(fileResult.Added) > 0 {
+			if err := ensureInclude(configPath, ManagedFile); err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// ReconcilePlan computes what Reconcile would do with the same arguments,
+// including the field-level changes of the updated hosts, without writing
+// anything
+func ReconcilePlan(desired []SSHHost, pruneExtra bool) (ReconcileResult, error) {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return ReconcileResult{}, err
+	}
+	paths, targets, err := reconcileTargets(configPath, desired)
+	if err != nil {
+		return ReconcileResult{}, err
+	}
+
+	var result ReconcileResult
+	for _, path := range paths {
+		_, fileResult, err := planReconcileFile(path, targets[path], pruneExtra)
+		if err != nil {
+			return result, err
+		}
+		result.merge(fileResult)
+	}
+	return result, nil
+}
+
+// merge adds the hosts and changes of other to r
+func (r *ReconcileResult) merge(other ReconcileResult) {
+	r.Added = append(r.Added, other.Added...)
+	r.Updated = append(r.Updated, other.Updated...)
+	r.Deleted = append(r.Deleted, other.Deleted...)
+	for name, changes := range other.Changes {
+		if r.Changes == nil {
+			r.Changes = make(map[string][]HostChange)
+		}
+		r.Changes[name] = changes
+	}
+}
+
+// reconcileTargets splits desired between the files Reconcile writes: hosts
+// defined in configPath or a file it includes are reconciled where they are
+// defined, the others in ManagedFile when it is set, configPath otherwise. It
+// returns the files, in order, and the desired hosts of each.
+func reconcileTargets(configPath string, desired []SSHHost) ([]string, map[string][]SSHHost, error) {
+	newPath, err := managedFilePath(configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if newPath == "" {
+		newPath = configPath
+	}
+
+	paths, err := collectConfigFiles(configPath, make(map[string]bool))
+	if os.IsNotExist(err) {
+		paths = []string{configPath}
+	} else if err != nil {
+		return nil, nil, err
+	}
+	current, err := parseSSHConfigFile(configPath, make(map[string]bool))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+
+	targets := make(map[string][]SSHHost)
+	for _, host := range desired {
+		path := newPath
+		if existing, ok := findHost(current, host.Name); ok && existing.SourceFile != "" {
+			path = existing.SourceFile
+		}
+		targets[path] = append(targets[path], host)
+	}
+	if !slices.Contains(paths, newPath) {
+		paths = append(paths, newPath)
+	}
+	return paths, targets, nil
+}
+
+// recordReconcile records the operations of a reconcile pass
+func recordReconcile(result ReconcileResult, desired []SSHHost) error {
+	hosts := make(map[string]SSHHost, len(desired))
+	for _, host := range desired {
+		hosts[host.Name] = markManaged(host)
+	}
+	for _, ops := range []struct {
+		op    string
+		names []string
+	}{{OpAdd, result.Added}, {OpUpdate, result.Updated}} {
+		for _, name := range ops.names {
+			host := hosts[name]
+			if err := recordOperation(ops.op, name, &host); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range result.Deleted {
+		if err := recordOperation(OpDelete, name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planReconcileFile computes the lines of configPath after a reconcile pass
+// against the hosts defined in the file itself (hosts of included files are
+// not considered)
+func planReconcileFile(configPath string, desired []SSHHost, pruneExtra bool) ([]string, ReconcileResult, error) {
+	content, err := readConfigFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, ReconcileResult{}, err
+	}
+
+	var current []SSHHost
+	if err == nil {
+		current, err = parseSSHConfigFile(configPath, nil)
+		if err != nil {
+			return nil, ReconcileResult{}, err
+		}
+		if err := applySidecarMetadata(current); err != nil {
+			return nil, ReconcileResult{}, err
+		}
+	}
+
+	return reconcileLines(strings.Split(string(content), "\n"), current, desired, pruneExtra)
+}
+
+// reconcileFile runs a reconcile pass against configPath and writes the result
+func reconcileFile(configPath string, desired []SSHHost, pruneExtra bool) (ReconcileResult, error) {
+	lines, result, err := planReconcileFile(configPath, desired, pruneExtra)
+	if err != nil {
+		return result, err
+	}
+
+	if len(result.Added) == 0 && len(result.Updated) == 0 && len(result.Deleted) == 0 {
+		return result, nil
+	}
+
+	// Create backup before modification if file exists
+	if _, err := Files.Stat(configPath); err == nil {
+		if err := backupConfig(configPath); err != nil {
+			return result, fmt.Errorf("failed to create backup: %w", err)
+		}
+	}
+
+	changed := make(map[string]bool)
+	for _, name := range append(result.Added, result.Updated...) {
+		changed[name] = true
+	}
+	var written []SSHHost
+	for _, host := range desired {
+		if changed[host.Name] {
+			written = append(written, markManaged(host))
+		}
+	}
+	err = writeWithSidecarMetadata(func() error {
+		return writeConfigFile(configPath, []byte(strings.Join(lines, "\n")))
+	}, written, result.Deleted...)
+	return result, err
+}
+
+// reconcileLines applies a reconcile pass to the given config lines
+func reconcileLines(lines []string, current, desired []SSHHost, pruneExtra bool) ([]string, ReconcileResult, error) {
+	var result ReconcileResult
+
+	existing := make(map[string]SSHHost, len(current))
+	for _, host := range current {
+		existing[host.Name] = host
+	}
+
+	wanted := make(map[string]bool, len(desired))
+	for _, host := range desired {
+		if wanted[host.Name] {
+			return lines, result, fmt.Errorf("host '%s' is listed more than once", host.Name)
+		}
+		wanted[host.Name] = true
+	}
+
+	for _, host := range desired {
+		host = markManaged(host)
+
+		old, ok := existing[host.Name]
+		if !ok {
+			if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+				lines = lines[:len(lines)-1]
+			}
+			lines = append(lines, "")
+			lines = append(lines, formatHostBlock(host)...)
+			lines = append(lines, "")
+			result.Added = append(result.Added, host.Name)
+			continue
+		}
+
+		changes := DiffHosts(old, host)
+		if len(changes) == 0 {
+			continue
+		}
+		lines, _ = replaceHostBlock(lines, host.Name, host)
+		result.Updated = append(result.Updated, host.Name)
+		if result.Changes == nil {
+			result.Changes = make(map[string][]HostChange)
+		}
+		result.Changes[host.Name] = changes
+	}
+
+	if pruneExtra {
+		for _, host := range current {
+			if wanted[host.Name] || !host.IsManaged() {
+				continue
+			}
+			lines, _ = removeHostBlock(lines, host.Name)
+			result.Deleted = append(result.Deleted, host.Name)
+		}
+	}
+
+	return lines, result, nil
+}
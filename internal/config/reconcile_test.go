@@ -0,0 +1,124 @@
+package config
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReconcileSettles(t *testing.T) {
+	path := useTestConfig(t, "Host web\n    HostName web.example\n    Compression yes\n")
+	desired := []SSHHost{
+		{Name: "web", Hostname: "web.example", Port: "22"},
+		{Name: "db", Hostname: "db.example", Port: "22"},
+	}
+
+	first, err := Reconcile(desired, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Added) != 1 || len(first.Updated) != 1 {
+		t.Fatalf("first Reconcile() = %+v, want db added and web updated", first)
+	}
+	content := readTestFile(t, path)
+
+	second, err := Reconcile(desired, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second.Added)+len(second.Updated)+len(second.Deleted) != 0 {
+		t.Errorf("second Reconcile() = %+v, want no changes", second)
+	}
+	if got := readTestFile(t, path); got != content {
+		t.Errorf("second Reconcile() rewrote the config to %q", got)
+	}
+	if web := hostNamed(t, mustParse(t, content), "web"); web.Extra["Compression"] == nil {
+		t.Error("Reconcile() dropped the Compression directive of web")
+	}
+}
+
+func TestReconcileManagedFile(t *testing.T) {
+	path := useTestConfig(t, "Host web\n    HostName web.example\n")
+	setForTest(t, &ManagedFile, "gosshm_hosts")
+	opsPath := filepath.Join(filepath.Dir(path), "ops.jsonl")
+	setForTest(t, &RecordOperationsTo, opsPath)
+
+	desired := []SSHHost{
+		{Name: "web", Hostname: "web2.example", Port: "22"},
+		{Name: "db", Hostname: "db.example", Port: "22"},
+	}
+	plan, err := ReconcilePlan(desired, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Reconcile(desired, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plan, result) {
+		t.Errorf("ReconcilePlan() = %+v, Reconcile() = %+v", plan, result)
+	}
+	if !reflect.DeepEqual(result.Added, []string{"db"}) || !reflect.DeepEqual(result.Updated, []string{"web"}) {
+		t.Fatalf("Reconcile() = %+v, want db added and web updated", result)
+	}
+
+	config := readTestFile(t, path)
+	if !strings.HasPrefix(config, "Include gosshm_hosts\n") || !strings.Contains(config, "HostName web2.example") {
+		t.Errorf("config = %q, want the Include and web updated in place", config)
+	}
+	if managed := readTestFile(t, filepath.Join(filepath.Dir(path), "gosshm_hosts")); !strings.Contains(managed, "Host db") {
+		t.Errorf("managed file = %q, want db added", managed)
+	}
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(readTestFile(t, opsPath)), "\n") {
+		var op Operation
+		if err := json.Unmarshal([]byte(line), &op); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, op.Op+" "+op.Name)
+	}
+	if want := []string{"add db", "update web"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("recorded operations = %v, want %v", got, want)
+	}
+}
+
+func TestReconcileIncludedHost(t *testing.T) {
+	path, extraPath := useTestConfigWithInclude(t, "Host db\n    HostName db.example\n",
+		"Host web\n    HostName web.example\n")
+	desired := []SSHHost{
+		{Name: "web", Hostname: "web2.example", Port: "22"},
+		{Name: "db", Hostname: "db.example", Port: "22"},
+	}
+
+	result, err := Reconcile(desired, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Added) != 0 || !reflect.DeepEqual(result.Updated, []string{"db", "web"}) {
+		t.Fatalf("Reconcile() = %+v, want db and web updated", result)
+	}
+	if config := readTestFile(t, path); strings.Contains(config, "Host web") {
+		t.Errorf("config = %q, want web left in the included file", config)
+	}
+	if extra := readTestFile(t, extraPath); !strings.Contains(extra, "HostName web2.example") {
+		t.Errorf("included file = %q, want web updated in place", extra)
+	}
+
+	second, err := Reconcile(desired, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second.Added)+len(second.Updated)+len(second.Deleted) != 0 {
+		t.Errorf("second Reconcile() = %+v, want no changes", second)
+	}
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 2 {
+		t.Errorf("Reconcile() left %d hosts, want 2", len(hosts))
+	}
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"sshm/internal/validation"
+)
+
+// checkHostName reports whether name can be given to a new or renamed host.
+// It fails if a host with that name already exists, and returns a warning when
+// wildcard entries match the name, since their settings will apply to it too.
+func checkHostName(hosts []SSHHost, name string) (warning string, err error) {
+	var patterns []string
+	for _, host := range hosts {
+		if host.Name == name {
+			return "", fmt.Errorf("host '%s' already exists", name)
+		}
+		if IsPattern(host.Name) && matchesHost(host.Name, name) {
+			patterns = append(patterns, "'Host "+host.Name+"'")
+		}
+	}
+
+	if len(patterns) > 0 {
+		warning = fmt.Sprintf("'%s' is also matched by %s, whose settings will apply to it", name, strings.Join(patterns, ", "))
+	}
+	return warning, nil
+}
+
+// CheckHostName reports whether name is free to be used by a new or renamed
+// host. A non-empty warning means the name is allowed but wildcard entries of
+// the config will also apply to it.
+func CheckHostName(name string) (warning string, err error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return "", err
+	}
+	return checkHostName(hosts, name)
+}
+
+// CloneHost adds a copy of an existing host under a new name
+func CloneHost(hostName, newName string) error {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+
+	host, err := GetSSHHost(hostName)
+	if err != nil {
+		return err
+	}
+
+	if _, err := CheckHostName(newName); err != nil {
+		return err
+	}
+
+	clone := *host
+	clone.Name = newName
+	// The clone is a new host of the user's, not one gosshm manages
+	if clone.IsManaged() {
+		clone.Meta = make(map[string]string, len(host.Meta))
+		for k, v := range host.Meta {
+			if k != MetaManagedKey {
+				clone.Meta[k] = v
+			}
+		}
+	}
+	return AddSSHHost(clone)
+}
+
+// RenameSSHHost gives the host oldName the name newName, keeping all of its
+// settings and metadata. ProxyJump references to oldName are not updated.
+func RenameSSHHost(oldName, newName string) (err error) {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+	if !validation.ValidateHostName(newName) {
+		return fmt.Errorf("invalid host name '%s'", newName)
+	}
+
+	var renamed *SSHHost
+	defer func() {
+		if err == nil && renamed != nil {
+			err = recordOperation(OpUpdate, oldName, renamed)
+		}
+	}()
+
+	// The host is read and written under one lock, so that a concurrent edit
+	// is not overwritten
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	hosts, err := parseSSHConfig()
+	if err != nil {
+		return err
+	}
+	host, ok := findHost(hosts, oldName)
+	if !ok {
+		return fmt.Errorf("host '%s' not found", oldName)
+	}
+	if newName == oldName {
+		return nil
+	}
+	if _, err := checkHostName(hosts, newName); err != nil {
+		return err
+	}
+
+	host.Name = newName
+	if err := updateSSHHost(oldName, host); err != nil {
+		return err
+	}
+	renamed = &host
+	return nil
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+const renameConfig = `# Tags: web, prod
+Host web
+    HostName web.example
+    User deploy
+    IdentityFile ~/.ssh/id_web
+
+Host db
+    HostName db.example
+`
+
+func TestRenameSSHHost(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		wantErr  bool
+	}{
+		{"renamed", "web", "frontend", false},
+		{"same name", "web", "web", false},
+		{"taken", "web", "db", true},
+		{"missing", "cache", "redis", true},
+		{"invalid", "web", "bad name", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useTestConfig(t, renameConfig)
+			err := RenameSSHHost(tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RenameSSHHost() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if got := readTestFile(t, path); got != renameConfig {
+					t.Errorf("config changed to %q", got)
+				}
+				return
+			}
+
+			hosts, err := ParseSSHConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			host := hostNamed(t, hosts, tt.to)
+			if host.Hostname != "web.example" || host.User != "deploy" || host.Identity != "~/.ssh/id_web" ||
+				!reflect.DeepEqual(host.Tags, []string{"web", "prod"}) {
+				t.Errorf("renamed host = %+v, want the settings of web", host)
+			}
+			if tt.to != tt.from {
+				if _, ok := findHost(hosts, tt.from); ok {
+					t.Errorf("host %q still exists", tt.from)
+				}
+			}
+		})
+	}
+}
+
+const wildcardConfig = `Host web
+    HostName web.example
+
+Host db-* !db-legacy
+    User dba
+
+Host *
+    ServerAliveInterval 60
+`
+
+func TestCheckHostName(t *testing.T) {
+	tests := []struct {
+		name        string
+		newName     string
+		wantErr     bool
+		wantWarning string
+	}{
+		{"free", "cache", false, "'cache' is also matched by 'Host *', whose settings will apply to it"},
+		{"existing", "web", true, ""},
+		{"several patterns", "db-main", false, "'db-main' is also matched by 'Host db-* !db-legacy', 'Host *', whose settings will apply to it"},
+		{"negated pattern", "db-legacy", false, "'db-legacy' is also matched by 'Host *', whose settings will apply to it"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, wildcardConfig)
+			warning, err := CheckHostName(tt.newName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckHostName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if warning != tt.wantWarning {
+				t.Errorf("CheckHostName() warning = %q, want %q", warning, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestRenameAndCloneUnderWildcard(t *testing.T) {
+	tests := []struct {
+		name      string
+		write     func() error
+		wantErr   bool
+		wantHosts []string
+	}{
+		{"rename covered by Host *", func() error { return RenameSSHHost("web", "frontend") }, false, []string{"frontend"}},
+		{"rename covered by a pattern", func() error { return RenameSSHHost("web", "db-main") }, false, []string{"db-main"}},
+		{"clone covered by Host *", func() error { return CloneHost("web", "frontend") }, false, []string{"web", "frontend"}},
+		{"clone onto existing", func() error { return CloneHost("web", "web") }, true, nil},
+		{"clone of missing host", func() error { return CloneHost("cache", "redis") }, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useTestConfig(t, wildcardConfig)
+			err := tt.write()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if got := readTestFile(t, path); got != wildcardConfig {
+					t.Errorf("config changed to %q", got)
+				}
+				return
+			}
+
+			hosts, err := ParseSSHConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, host := range hosts {
+				if !host.Pattern {
+					names = append(names, host.Name)
+				}
+			}
+			if !reflect.DeepEqual(names, tt.wantHosts) {
+				t.Errorf("hosts = %q, want %q", names, tt.wantHosts)
+			}
+			for _, name := range tt.wantHosts {
+				if host := hostNamed(t, hosts, name); host.Hostname != "web.example" {
+					t.Errorf("host %q has HostName %q, want web.example", name, host.Hostname)
+				}
+			}
+		})
+	}
+}
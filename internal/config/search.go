@@ -0,0 +1,108 @@
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// fuzzyScore scores pattern as a subsequence of text, both lowercased: matched
+// characters that follow each other or start a word score more, skipped ones
+// less. ok is false when text doesn't contain every character of pattern in
+// order.
+func fuzzyScore(pattern, text string) (score int, ok bool) {
+	if pattern == "" {
+		return 0, true
+	}
+
+	best, found := 0, false
+	// Try each place where the match could start, as the first occurrence of
+	// the first character isn't always the best one ("db" in "dev-db")
+	for start := 0; start < len(text); start++ {
+		if text[start] != pattern[0] {
+			continue
+		}
+		s, p, last := 0, 0, -1
+		for i := start; i < len(text) && p < len(pattern); i++ {
+			if text[i] != pattern[p] {
+				continue
+			}
+			s++
+			switch {
+			case last >= 0 && i == last+1:
+				s += 5
+			case i == 0 || strings.IndexByte("-_. @/:", text[i-1]) >= 0:
+				s += 8
+			}
+			if last >= 0 {
+				s -= min(i-last-1, 5)
+			}
+			last = i
+			p++
+		}
+		if p < len(pattern) {
+			break // Later starts leave even less of text to match
+		}
+		if text == pattern {
+			s += 20
+		}
+		if !found || s > best {
+			best, found = s, true
+		}
+	}
+	return best, found
+}
+
+// searchFields are the fields SearchHosts looks at, with the bonus a match in
+// each earns, so that a name beats a tag that beats an address
+var searchFields = []struct {
+	bonus  int
+	values func(SSHHost) []string
+}{
+	{10, func(h SSHHost) []string { return []string{h.Name} }},
+	{5, func(h SSHHost) []string { return h.Tags }},
+	{0, func(h SSHHost) []string { return []string{h.Hostname, h.User} }},
+}
+
+// SearchHosts returns the hosts matching every word of query, fuzzily, in their
+// name, hostname, user or tags, best matches first. Hosts scoring the same keep
+// their order, and an empty query returns hosts as is.
+func SearchHosts(hosts []SSHHost, query string) []SSHHost {
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) == 0 {
+		return hosts
+	}
+
+	type match struct {
+		host  SSHHost
+		score int
+	}
+	var matches []match
+	for _, host := range hosts {
+		total, matched := 0, true
+		for _, term := range terms {
+			best, found := 0, false
+			for _, field := range searchFields {
+				for _, value := range field.values(host) {
+					if s, ok := fuzzyScore(term, strings.ToLower(value)); ok && (!found || s+field.bonus > best) {
+						best, found = s+field.bonus, true
+					}
+				}
+			}
+			if !found {
+				matched = false
+				break
+			}
+			total += best
+		}
+		if matched {
+			matches = append(matches, match{host, total})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	found := make([]SSHHost, len(matches))
+	for i, m := range matches {
+		found[i] = m.host
+	}
+	return found
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// sidecarSuffix is appended to the config path to name the sidecar file, which
+// stores the state gosshm keeps outside of the SSH config itself
+const sidecarSuffix = ".gosshm.json"
+
+// sidecarMutex protects sidecar file operations from race conditions
+var sidecarMutex sync.Mutex
+
+// usageRecord holds the connection history of a host
+type usageRecord struct {
+	Connections    int       `json:"connections"`
+	FirstConnected time.Time `json:"first_connected"`
+	LastConnected  time.Time `json:"last_connected"`
+}
+
+// sidecarData is the content of the sidecar file
+type sidecarData struct {
+	Usage        map[string]*usageRecord  `json:"usage,omitempty"`
+	Reachability map[string]ReachResult   `json:"reachability,omitempty"`
+	Metadata     map[string]*hostMetadata `json:"metadata,omitempty"`
+}
+
+// getSidecarPath returns the path of the sidecar file
+func getSidecarPath() (string, error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return configPath + sidecarSuffix, nil
+}
+
+// readSidecar reads the sidecar file. A missing sidecar yields empty data; an
+// unreadable or corrupt one is an error.
+func readSidecar() (*sidecarData, error) {
+	data := &sidecarData{}
+
+	path, err := getSidecarPath()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := readFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read sidecar %s: %w", path, err)
+	default:
+		if err := json.Unmarshal(content, data); err != nil {
+			return nil, fmt.Errorf("corrupt sidecar %s: %w", path, err)
+		}
+	}
+
+	if data.Usage == nil {
+		data.Usage = make(map[string]*usageRecord)
+	}
+	return data, nil
+}
+
+// loadSidecar reads the sidecar file. A missing, unreadable or corrupt sidecar
+// yields empty data, so that reads keep working without it. Only writes of
+// host metadata, which would lose what the file holds, check it with
+// readSidecar.
+func loadSidecar() (*sidecarData, error) {
+	if _, err := getSidecarPath(); err != nil {
+		return nil, err
+	}
+	data, err := readSidecar()
+	if err != nil {
+		return &sidecarData{Usage: make(map[string]*usageRecord)}, nil
+	}
+	return data, nil
+}
+
+// saveSidecar writes the sidecar file
+func saveSidecar(data *sidecarData) error {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+
+	path, err := getSidecarPath()
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicWriteFile(path, content, 0600)
+}
+
+// RecordConnection records a connection to the named host in the sidecar
+func RecordConnection(hostName string) error {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+
+	sidecarMutex.Lock()
+	defer sidecarMutex.Unlock()
+
+	data, err := loadSidecar()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	record, ok := data.Usage[hostName]
+	if !ok {
+		record = &usageRecord{FirstConnected: now}
+		data.Usage[hostName] = record
+	}
+	record.Connections++
+	record.LastConnected = now
+
+	return saveSidecar(data)
+}
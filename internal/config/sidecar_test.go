@@ -0,0 +1,197 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadSidecar(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string // Empty for no sidecar
+		wantErr bool
+	}{
+		{"missing", "", false},
+		{"valid", `{"usage": {"web": {"connections": 3}}}`, false},
+		{"corrupt", `{"metadata": {`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := useTestConfig(t, "Host web\n    HostName web.example\n")
+			if tt.content != "" {
+				if err := os.WriteFile(configPath+sidecarSuffix, []byte(tt.content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := readSidecar(); (err != nil) != tt.wantErr {
+				t.Fatalf("readSidecar() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			// Reads fall back to empty data
+			data, err := loadSidecar()
+			if err != nil || data.Usage == nil {
+				t.Fatalf("loadSidecar() = %+v, %v, want data", data, err)
+			}
+		})
+	}
+}
+
+const corruptSidecar = `{"metadata": {"db": {"tags": ["prod"]`
+
+// useCorruptSidecar sets up a config of web and db, in sidecar mode, with a
+// corrupt sidecar, and returns the path of the config
+func useCorruptSidecar(t *testing.T) string {
+	t.Helper()
+	configPath := useTestConfig(t, "Host web\n    HostName web.example\n    ProxyJump db\n\nHost db\n    HostName db.example\n")
+	setForTest(t, &MetadataMode, MetadataInSidecar)
+	if err := os.WriteFile(configPath+sidecarSuffix, []byte(corruptSidecar), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return configPath
+}
+
+func TestCorruptSidecarReads(t *testing.T) {
+	tests := []struct {
+		name string
+		read func() error
+	}{
+		{"GetSSHHost", func() error { _, err := GetSSHHost("web"); return err }},
+		{"ParseSSHConfig", func() error { _, err := ParseSSHConfig(); return err }},
+		{"ConnectionStats", func() error { _, err := ConnectionStats(); return err }},
+		{"GetNeverConnectedHosts", func() error { _, err := GetNeverConnectedHosts(); return err }},
+		{"GetJumpOnlyHosts", func() error { _, err := GetJumpOnlyHosts(); return err }},
+		{"LastReachability", func() error { _, err := LastReachability(); return err }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useCorruptSidecar(t)
+			if err := tt.read(); err != nil {
+				t.Errorf("error = %v", err)
+			}
+		})
+	}
+}
+
+func TestCorruptSidecarWrites(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func() error
+	}{
+		{"add", func() error { return AddSSHHost(SSHHost{Name: "cache", Hostname: "cache.example"}) }},
+		{"update", func() error { return UpdateSSHHost("web", SSHHost{Name: "web", Hostname: "web2.example"}) }},
+		{"update streaming", func() error {
+			return UpdateSSHHostStreaming("web", SSHHost{Name: "web", Hostname: "web2.example"})
+		}},
+		{"delete", func() error { return DeleteSSHHost("db") }},
+		{"delete streaming", func() error { return DeleteSSHHostStreaming("db") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := useCorruptSidecar(t)
+			config := readTestFile(t, configPath)
+
+			if err := tt.write(); err == nil {
+				t.Fatal("write succeeded over a corrupt sidecar")
+			}
+			if got := readTestFile(t, configPath+sidecarSuffix); got != corruptSidecar {
+				t.Errorf("sidecar was rewritten to %q", got)
+			}
+			if got := readTestFile(t, configPath); got != config {
+				t.Errorf("config changed to %q", got)
+			}
+		})
+	}
+}
+
+func TestSidecarRestoredOnConfigFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		sidecar string // Empty for no sidecar
+	}{
+		{"existing sidecar", `{"metadata": {"web": {"tags": ["prod"]}}}`},
+		{"no sidecar", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := useTestConfig(t, "Host web\n    HostName web.example\n")
+			setForTest(t, &MetadataMode, MetadataInSidecar)
+			sidecarPath := configPath + sidecarSuffix
+			if tt.sidecar != "" {
+				if err := os.WriteFile(sidecarPath, []byte(tt.sidecar), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			setForTest[FileSystem](t, &Files, failingRename{path: configPath})
+
+			err := UpdateSSHHost("web", SSHHost{Name: "web", Hostname: "web.example", Tags: []string{"dev"}})
+			if err == nil {
+				t.Fatal("UpdateSSHHost() succeeded, want the write of the config to fail")
+			}
+
+			content, err := os.ReadFile(sidecarPath)
+			if tt.sidecar == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("sidecar was left behind: %q", content)
+				}
+				return
+			}
+			if string(content) != tt.sidecar {
+				t.Errorf("sidecar = %q, want %q", content, tt.sidecar)
+			}
+		})
+	}
+}
+
+func TestSidecarMetadataSaved(t *testing.T) {
+	configPath := useTestConfig(t, "Host web\n    HostName web.example\n")
+	setForTest(t, &MetadataMode, MetadataInSidecar)
+
+	if err := UpdateSSHHost("web", SSHHost{Name: "web", Hostname: "web.example", Tags: []string{"dev"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readTestFile(t, configPath), "Host web\n    HostName web.example\n"; got != want {
+		t.Errorf("config = %q, want %q", got, want)
+	}
+	web, err := GetSSHHost("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(web.Tags, []string{"dev"}) {
+		t.Errorf("Tags = %q, want dev", web.Tags)
+	}
+}
+
+func TestSidecarMetadataInReports(t *testing.T) {
+	useTestConfig(t, "Host web\n    HostName web.example\n")
+	setForTest(t, &MetadataMode, MetadataInSidecar)
+	if err := UpdateSSHHost("web", SSHHost{Name: "web", Hostname: "web.example", Tags: []string{"dev"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	dump, err := DumpModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var model ModelDump
+	if err := json.Unmarshal(dump, &model); err != nil {
+		t.Fatal(err)
+	}
+	if len(model.Hosts) != 1 || !reflect.DeepEqual(model.Hosts[0].Tags, []string{"dev"}) {
+		t.Errorf("DumpModel() hosts = %+v, want web tagged dev", model.Hosts)
+	}
+
+	var metrics strings.Builder
+	if err := WritePrometheusMetrics(&metrics); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(metrics.String(), "gosshm_hosts_by_tag{tag=\"dev\"} 1\n") {
+		t.Errorf("WritePrometheusMetrics() = %q, want web counted for dev", metrics.String())
+	}
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// SortKey selects the field SortHosts orders hosts by
+type SortKey int
+
+const (
+	SortByName SortKey = iota
+	SortByHostname
+	SortByTag // First tag, hosts without tags last
+)
+
+// sortValue returns the lowercased value of host compared for key
+func sortValue(host SSHHost, key SortKey) string {
+	switch key {
+	case SortByHostname:
+		return strings.ToLower(host.Hostname)
+	case SortByTag:
+		if len(host.Tags) > 0 {
+			return strings.ToLower(host.Tags[0])
+		}
+		return ""
+	}
+	return strings.ToLower(host.Name)
+}
+
+// SortHosts orders hosts by key, ignoring case. The sort is stable: hosts with
+// equal keys stay in config order.
+func SortHosts(hosts []SSHHost, by SortKey) {
+	sort.SliceStable(hosts, func(i, j int) bool {
+		a, b := sortValue(hosts[i], by), sortValue(hosts[j], by)
+		// Hosts without the value go last
+		if (a == "") != (b == "") {
+			return b == ""
+		}
+		return a < b
+	})
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortHosts(t *testing.T) {
+	// SourceFile tells apart the hosts sharing a name
+	hosts := []SSHHost{
+		{Name: "web", Hostname: "b.example", SourceFile: "config", Tags: []string{"prod"}},
+		{Name: "Bastion", Hostname: "A.example", SourceFile: "config"},
+		{Name: "db", Hostname: "c.example", SourceFile: "config", Tags: []string{"Prod"}},
+		{Name: "web", Hostname: "a.example", SourceFile: "extra.conf", Tags: []string{"dev"}},
+		{Name: "api", SourceFile: "extra.conf", Tags: []string{"prod"}},
+	}
+	type position struct {
+		name, file string
+	}
+
+	tests := []struct {
+		name string
+		by   SortKey
+		want []position
+	}{
+		{
+			name: "name",
+			by:   SortByName,
+			want: []position{{"api", "extra.conf"}, {"Bastion", "config"}, {"db", "config"}, {"web", "config"}, {"web", "extra.conf"}},
+		},
+		{
+			name: "hostname",
+			by:   SortByHostname,
+			want: []position{{"Bastion", "config"}, {"web", "extra.conf"}, {"web", "config"}, {"db", "config"}, {"api", "extra.conf"}},
+		},
+		{
+			name: "tag",
+			by:   SortByTag,
+			want: []position{{"web", "extra.conf"}, {"web", "config"}, {"db", "config"}, {"api", "extra.conf"}, {"Bastion", "config"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted := append([]SSHHost(nil), hosts...)
+			SortHosts(sorted, tt.by)
+			var got []position
+			for _, host := range sorted {
+				got = append(got, position{host.Name, host.SourceFile})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SortHosts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortHostsStable(t *testing.T) {
+	hosts := []SSHHost{
+		{Name: "web", SourceFile: "config"},
+		{Name: "db", SourceFile: "config"},
+		{Name: "Web", SourceFile: "extra.conf"},
+		{Name: "web", SourceFile: "other.conf"},
+	}
+	want := []string{"config", "config", "extra.conf", "other.conf"}
+
+	// Sorting again must not swap the hosts sharing a name
+	for i := 0; i < 3; i++ {
+		SortHosts(hosts, SortByName)
+		var got []string
+		for _, host := range hosts {
+			got = append(got, host.SourceFile)
+		}
+		if !reflect.DeepEqual(got, want) || hosts[0].Name != "db" {
+			t.Fatalf("pass %d: SortHosts() gave %+v", i+1, hosts)
+		}
+	}
+}
@@ -2,76 +2,757 @@ package config
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"time"
+	"unicode"
 )
 
 // SSHHost represents an SSH host configuration
 type SSHHost struct {
-	Name      string
-	Hostname  string
-	User      string
-	Port      string
-	Identity  string
-	ProxyJump string
-	Tags      []string
+	Name          string
+	Hostname      string
+	User          string
+	Port          string
+	Identity      string // First of Identities; see SetIdentities
+	ProxyJump     string
+	ProxyCommand  string // Kept as written, quotes included
+	ControlMaster string
+	ControlPath   string
+	// Algorithm lists are kept verbatim, including +/-/^ prefixes
+	Ciphers       string
+	MACs          string
+	KexAlgorithms string
+	// AddressFamily is any, inet or inet6, as written
+	AddressFamily string
+	BindAddress   string
+	// X11 forwarding settings, yes or no as written
+	ForwardX11        string
+	ForwardX11Trusted string
+	// Identities holds every IdentityFile, in the order ssh tries them. Identity
+	// mirrors the first one for callers handling a single key: changing it
+	// replaces the first key, and clearing it removes them all.
+	Identities []string
+	// DynamicForwards holds the [bind_address:]port specs of SOCKS proxies, in order
+	DynamicForwards []string
+	// LocalForwards and RemoteForwards hold the port forwarding specs, in order
+	LocalForwards  []string
+	RemoteForwards []string
+	// Connection settings, as written
+	ForwardAgent          string
+	ServerAliveInterval   string
+	StrictHostKeyChecking string
+	ConnectTimeout        string
+	// Comments are the free-form comment lines right above the Host line, as
+	// written (with their "#"). A nil slice keeps the existing ones on update.
+	Comments []string
+	Tags     []string
+	Group    string
+	Meta     map[string]string
+	// Profiles maps environment names to the HostName to use in each of them,
+	// as set by a "# Profiles: dev=10.0.1.5, prod=10.0.2.5" comment
+	Profiles map[string]string
+	Launcher string
+	// Options are extra "Key=Value" options passed to ssh with -o on connect
+	Options []string
+	// Extra holds the directives gosshm does not model, keyed by keyword as
+	// written in the config, so that they survive a rewrite of the block
+	Extra map[string][]string
+
+	// Pattern is set by the parser on entries that match hosts by wildcard
+	// (e.g. "*.staging") rather than naming one host that can be connected to
+	Pattern bool
+
+	// Location of the block, filled in by the parser
+	SourceFile string
+	LineNumber int // Line of the Host directive
+	EndLine    int // Last line holding a directive of the block
 }
 
-// configMutex protects SSH config file operations from race conditions
-var configMutex sync.Mutex
+// configMutex protects SSH config file operations from race conditions. Reads
+// share it, so that they never see a half-written file but don't wait for each
+// other. Functions holding it must call the unexported readers, since it is
+// not reentrant.
+var configMutex sync.RWMutex
 
-// backupConfig creates a backup of the SSH config file
+// ReadOnly disables every function that modifies the config, its backups, the
+// sidecar or control sockets: they return ErrReadOnly without touching the disk
+var ReadOnly bool
+
+// ErrReadOnly is returned by the functions that modify files when ReadOnly is set
+var ErrReadOnly = errors.New("gosshm is in read-only mode")
+
+// backupConfig creates a timestamped backup of the SSH config file and prunes
+// the backups beyond BackupRetention
 func backupConfig(configPath string) error {
-	backupPath := configPath + ".backup"
-	src, err := os.Open(configPath)
+	if ReadOnly {
+		return ErrReadOnly
+	}
+
+	if err := migrateLegacyBackup(configPath); err != nil {
+		return err
+	}
+
+	backupPath := timestampedBackupPath(configPath, time.Now())
+	src, err := Files.Open(configPath)
 	if err != nil {
 		return err
 	}
 	defer src.Close()
 
-	dst, err := os.Create(backupPath)
+	dst, err := Files.Create(backupPath, 0600)
 	if err != nil {
 		return err
 	}
-	defer dst.Close()
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return pruneBackups(configPath)
+}
+
+// ConfigPath is the SSH config file gosshm reads and edits, e.g. a project-local
+// config or a test fixture. When empty, the file named by the GOSSHM_CONFIG
+// environment variable is used, then the default ~/.ssh/config.
+var ConfigPath string
+
+// configPathEnv names the environment variable holding the config path
+const configPathEnv = "GOSSHM_CONFIG"
+
+// customConfigPath returns the config path set through ConfigPath or the
+// environment, or an empty string when the default one is used
+func customConfigPath() string {
+	if ConfigPath != "" {
+		return ConfigPath
+	}
+	path := os.Getenv(configPathEnv)
+	if strings.HasPrefix(path, "~/") {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(homeDir, path[2:])
+		}
+	}
+	return path
+}
+
+// getConfigPath returns the path of the SSH config file gosshm works on
+func getConfigPath() (string, error) {
+	if path := customConfigPath(); path != "" {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".ssh", "config"), nil
+}
+
+// lineKeyword returns the lowercased keyword of a config line, or an empty
+// string for empty lines
+func lineKeyword(line string) string {
+	keyword, _ := splitDirective(line)
+	return strings.ToLower(keyword)
+}
+
+// splitDirective splits a config line into its keyword and value. As in ssh,
+// the keyword may be followed by whitespace or by "=". The value is kept as
+// written, quotes and inner spacing included, so that it round-trips.
+func splitDirective(line string) (keyword, value string) {
+	line = strings.TrimSpace(line)
+	end := strings.IndexFunc(line, func(r rune) bool { return unicode.IsSpace(r) || r == '=' })
+	if end < 0 {
+		return line, ""
+	}
+	value = strings.TrimLeftFunc(line[end:], unicode.IsSpace)
+	if rest, ok := strings.CutPrefix(value, "="); ok {
+		value = strings.TrimLeftFunc(rest, unicode.IsSpace)
+	}
+	return line[:end], value
+}
+
+// configArgs splits a directive value into its arguments, separated by
+// whitespace outside double quotes, with the quotes removed
+func configArgs(value string) []string {
+	var args []string
+	var arg strings.Builder
+	inArg, quoted := false, false
+	for _, r := range value {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inArg = true
+		case unicode.IsSpace(r) && !quoted:
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// isBlockBoundary reports whether a config line ends the directives of the
+// block above it. As in ssh, an Include does not: the lines after it still
+// belong to the block.
+func isBlockBoundary(line string) bool {
+	switch lineKeyword(line) {
+	case "host", "match":
+		return true
+	}
+	return false
+}
+
+// isHostLine reports whether a trimmed config line opens the block for hostName,
+// alone or along with other patterns
+func isHostLine(line, hostName string) bool {
+	keyword, value := splitDirective(line)
+	if strings.ToLower(keyword) != "host" {
+		return false
+	}
+	patterns := configArgs(value)
+	names := strings.Fields(hostName)
+	if len(names) == 0 {
+		return false
+	}
+	for _, name := range names {
+		if !slices.Contains(patterns, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// otherHostPatterns returns the patterns of a Host line opening the block for
+// hostName that are not part of hostName, which share the block with it
+func otherHostPatterns(line, hostName string) []string {
+	names := strings.Fields(hostName)
+	_, value := splitDirective(line)
+	var others []string
+	for _, pattern := range configArgs(value) {
+		if !slices.Contains(names, pattern) {
+			others = append(others, pattern)
+		}
+	}
+	return others
+}
+
+// hostLineIndex returns the index of the Host line of hostName within a block
+// found by findHostBlock
+func hostLineIndex(lines []string, start int, hostName string) int {
+	for start < len(lines) && !isHostLine(strings.TrimSpace(lines[start]), hostName) {
+		start++
+	}
+	return start
+}
+
+// structuredCommentPrefixes lists the comments gosshm attaches to the following Host
+var structuredCommentPrefixes = []string{"# Tags:", "# Group:", "# Meta:", "# Profiles:", "# Launcher:", "# Options:"}
+
+// isStructuredComment reports whether a trimmed line is one of the comments
+// gosshm attaches to the following Host (e.g. "# Tags:")
+func isStructuredComment(line string) bool {
+	for _, prefix := range structuredCommentPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// findHostBlock locates the block of hostName within lines. It returns the index
+// of the first line belonging to the block (including the comments right above
+// the Host line) and the index just past its last line. Structured comments are
+// applied by the parser to the next Host even across empty lines and other
+// comments, so the block starts at the first of them in that case.
+func findHostBlock(lines []string, hostName string) (start, end int, found bool) {
+	for i, line := range lines {
+		if !isHostLine(strings.TrimSpace(line), hostName) {
+			continue
+		}
+		start = i
+		for j := i - 1; j >= 0; j-- {
+			above := strings.TrimSpace(lines[j])
+			if above != "" && !strings.HasPrefix(above, "#") {
+				break
+			}
+			if isStructuredComment(above) {
+				start = j
+			}
+		}
+		for start > 0 && strings.HasPrefix(strings.TrimSpace(lines[start-1]), "#") {
+			start--
+		}
+		return start, hostBlockEnd(lines, i), true
+	}
+	return 0, 0, false
+}
+
+// hostBlockEnd returns the index just past the block whose Host line is at start.
+// As in ssh, every line up to the next block boundary belongs to the block,
+// whatever its indentation and even after an empty line; the empty lines and
+// comments that precede the boundary are left out.
+func hostBlockEnd(lines []string, start int) int {
+	next := start + 1
+	for next < len(lines) && !isBlockBoundary(lines[next]) {
+		next++
+	}
+
+	end := next
+	for end > start+1 {
+		line := strings.TrimSpace(lines[end-1])
+		if line != "" && !strings.HasPrefix(line, "#") {
+			break
+		}
+		end--
+	}
+	return end
+}
+
+// formatHostBlock renders the config lines for a host, including its
+// structured comments unless metadata is stored in the sidecar
+func formatHostBlock(host SSHHost) []string {
+	lines := append([]string{}, host.Comments...)
+
+	if MetadataMode == MetadataInSidecar {
+		host.Tags, host.Group, host.Meta, host.Launcher, host.Options = nil, "", nil, "", nil
+		host.Profiles = nil
+	}
+
+	if len(host.Tags) > 0 {
+		lines = append(lines, "# Tags: "+strings.Join(host.Tags, ", "))
+	}
+	if host.Group != "" {
+		lines = append(lines, "# Group: "+host.Group)
+	}
+	if len(host.Meta) > 0 {
+		lines = append(lines, "# Meta: "+formatMeta(host.Meta))
+	}
+	if len(host.Profiles) > 0 {
+		lines = append(lines, "# Profiles: "+formatMeta(host.Profiles))
+	}
+	if host.Launcher != "" {
+		lines = append(lines, "# Launcher: "+host.Launcher)
+	}
+	if len(host.Options) > 0 {
+		lines = append(lines, "# Options: "+strings.Join(host.Options, "; "))
+	}
+
+	lines = append(lines, "Host "+host.Name)
+	for _, d := range hostDirectives(host) {
+		lines = append(lines, "    "+d.Key+" "+d.Value)
+	}
+
+	return lines
+}
+
+// modeledDirectives holds the lowercased keywords parsed into SSHHost fields
+var modeledDirectives = map[string]bool{
+	"hostname":              true,
+	"user":                  true,
+	"port":                  true,
+	"identityfile":          true,
+	"proxyjump":             true,
+	"proxycommand":          true,
+	"controlmaster":         true,
+	"controlpath":           true,
+	"ciphers":               true,
+	"macs":                  true,
+	"kexalgorithms":         true,
+	"addressfamily":         true,
+	"bindaddress":           true,
+	"forwardx11":            true,
+	"forwardx11trusted":     true,
+	"forwardagent":          true,
+	"serveraliveinterval":   true,
+	"stricthostkeychecking": true,
+	"connecttimeout":        true,
+}
+
+// forwardDirectives holds the lowercased keywords of the forwards parsed into
+// SSHHost slices, every value of which is kept
+var forwardDirectives = map[string]bool{
+	"dynamicforward": true,
+	"localforward":   true,
+	"remoteforward":  true,
+}
+
+// directive is a keyword and value pair of a host block
+type directive struct {
+	Key   string
+	Value string
+}
+
+// hostDirectives returns the directives of a host in the order they are written
+// to the config. Empty settings and the default port are left out.
+func hostDirectives(host SSHHost) []directive {
+	var directives []directive
+	add := func(key, value string) {
+		if value != "" {
+			directives = append(directives, directive{key, value})
+		}
+	}
+
+	add("HostName", host.Hostname)
+	add("User", host.User)
+	if host.Port != "22" {
+		add("Port", host.Port)
+	}
+	for _, identity := range hostIdentities(host) {
+		add("IdentityFile", identity)
+	}
+	add("ProxyJump", host.ProxyJump)
+	add("ProxyCommand", host.ProxyCommand)
+	add("ControlMaster", host.ControlMaster)
+	add("ControlPath", host.ControlPath)
+	add("Ciphers", host.Ciphers)
+	add("MACs", host.MACs)
+	add("KexAlgorithms", host.KexAlgorithms)
+	add("AddressFamily", host.AddressFamily)
+	add("BindAddress", host.BindAddress)
+	add("ForwardX11", host.ForwardX11)
+	add("ForwardX11Trusted", host.ForwardX11Trusted)
+	for _, forward := range host.DynamicForwards {
+		add("DynamicForward", forward)
+	}
+	add("ForwardAgent", host.ForwardAgent)
+	for _, forward := range host.LocalForwards {
+		add("LocalForward", forward)
+	}
+	for _, forward := range host.RemoteForwards {
+		add("RemoteForward", forward)
+	}
+	add("ServerAliveInterval", host.ServerAliveInterval)
+	add("StrictHostKeyChecking", host.StrictHostKeyChecking)
+	add("ConnectTimeout", host.ConnectTimeout)
+
+	keys := make([]string, 0, len(host.Extra))
+	for key := range host.Extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		// Identity files are all written from Identities
+		if strings.EqualFold(key, "IdentityFile") {
+			continue
+		}
+		for _, value := range host.Extra[key] {
+			add(key, value)
+		}
+	}
+
+	return directives
+}
+
+// replaceHostBlock replaces the block of hostName with the rendering of newHost
+func replaceHostBlock(lines []string, hostName string, newHost SSHHost) ([]string, bool) {
+	start, end, found := findHostBlock(lines, hostName)
+	if !found {
+		return lines, false
+	}
+
+	// A host sharing its Host line with others is moved to a block of its own,
+	// right after the shared one, which keeps the comments above it
+	h := hostLineIndex(lines, start, hostName)
+	if others := otherHostPatterns(lines[h], hostName); len(others) > 0 {
+		shared := []string{}
+		for _, line := range lines[start:h] {
+			if line = strings.TrimSpace(line); !isStructuredComment(line) {
+				shared = append(shared, line)
+			}
+		}
+		if newHost.Comments == nil || slices.Equal(newHost.Comments, shared) {
+			newHost.Comments = []string{}
+		}
+		newLines := append([]string{}, lines[:h]...)
+		newLines = append(newLines, leadingIndent(lines[h])+"Host "+strings.Join(others, " "))
+		newLines = append(newLines, lines[h+1:end]...)
+		newLines = append(newLines, "")
+		newLines = append(newLines, formatHostBlock(newHost)...)
+		if end < len(lines) && strings.TrimSpace(lines[end]) != "" {
+			newLines = append(newLines, "")
+		}
+		return append(newLines, lines[end:]...), true
+	}
 
-	_, err = io.Copy(dst, src)
-	return err
+	if newHost.Comments == nil {
+		newHost.Comments = []string{}
+		for _, line := range lines[start:h] {
+			line = strings.TrimSpace(line)
+			if line != "" && !isStructuredComment(line) {
+				newHost.Comments = append(newHost.Comments, line)
+			}
+		}
+	}
+
+	// The comments and Host line are rendered, then the directives are merged
+	// into the lines of the block so that everything else is kept as written
+	block := formatHostBlock(newHost)
+	header := block[:len(block)-len(hostDirectives(newHost))]
+	if isHostLine(strings.TrimSpace(lines[h]), newHost.Name) {
+		header[len(header)-1] = lines[h]
+	}
+
+	newLines := append([]string{}, lines[:start]...)
+	newLines = append(newLines, header...)
+	newLines = append(newLines, mergeHostDirectives(lines[h+1:end], newHost)...)
+	return append(newLines, lines[end:]...), true
+}
+
+// mergeHostDirectives updates the body of a host block to the directives of
+// host. Lines whose directive keeps its value are left byte for byte, changed
+// values are rewritten in place, and new directives are added after the last
+// one. Comments, empty lines and lines the parser ignores are kept. Directives
+// gosshm does not model are only dropped when host.Extra is set and no longer
+// holds them.
+func mergeHostDirectives(body []string, host SSHHost) []string {
+	desired := hostDirectives(host)
+	used := make([]bool, len(desired))
+	next := func(key string) int {
+		for i, d := range desired {
+			if !used[i] && strings.EqualFold(d.Key, key) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	var merged []string
+	indent := "    "
+	insertAt := -1
+	for _, line := range body {
+		keyword, value := splitDirective(line)
+		if value == "" || strings.HasPrefix(keyword, "#") {
+			merged = append(merged, line)
+			continue
+		}
+		if leadingIndent(line) != "" {
+			indent = leadingIndent(line)
+		}
+
+		key := strings.ToLower(keyword)
+		if i := next(keyword); i >= 0 {
+			used[i] = true
+			if value != desired[i].Value {
+				line = leadingIndent(line) + keyword + " " + desired[i].Value
+			}
+		} else {
+			// The default port is left out of the directives but may be written
+			explicitDefault := key == "port" && value == "22" && effectivePort(host) == "22"
+			modeled := modeledDirectives[key] || forwardDirectives[key]
+			// Includes are not host settings and are kept where they are
+			if !explicitDefault && key != "include" && (modeled || host.Extra != nil) {
+				continue
+			}
+		}
+		merged = append(merged, line)
+		insertAt = len(merged)
+	}
+	if insertAt < 0 {
+		insertAt = len(merged)
+	}
+
+	var added []string
+	for i, d := range desired {
+		if !used[i] {
+			added = append(added, indent+d.Key+" "+d.Value)
+		}
+	}
+	return slices.Insert(merged, insertAt, added...)
+}
+
+// removeHostBlock removes the block of hostName, along with the empty line
+// that separates it from the next block
+func removeHostBlock(lines []string, hostName string) ([]string, bool) {
+	start, end, found := findHostBlock(lines, hostName)
+	if !found {
+		return lines, false
+	}
+
+	// A host sharing its Host line with others is only removed from that line
+	h := hostLineIndex(lines, start, hostName)
+	if others := otherHostPatterns(lines[h], hostName); len(others) > 0 {
+		newLines := append([]string{}, lines...)
+		newLines[h] = leadingIndent(lines[h]) + "Host " + strings.Join(others, " ")
+		return newLines, true
+	}
+
+	// Skip the empty line after the host block if it exists
+	if end < len(lines) && strings.TrimSpace(lines[end]) == "" {
+		end++
+	}
+
+	newLines := append([]string{}, lines[:start]...)
+	return append(newLines, lines[end:]...), true
 }
 
 // ParseSSHConfig parses the SSH config file and returns the list of hosts
 func ParseSSHConfig() ([]SSHHost, error) {
-	homeDir, err := os.UserHomeDir()
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return parseSSHConfig()
+}
+
+// parseSSHConfig is ParseSSHConfig for callers already holding configMutex
+func parseSSHConfig() ([]SSHHost, error) {
+	configPath, err := getConfigPath()
 	if err != nil {
 		return nil, err
 	}
-
-	configPath := filepath.Join(homeDir, ".ssh", "config")
-	return ParseSSHConfigFile(configPath)
+	hosts, err := parseSSHConfigFile(configPath, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	return hosts, applySidecarMetadata(hosts)
 }
 
-// ParseSSHConfigFile parses a specific SSH config file and returns the list of hosts
+// ParseSSHConfigFile parses a specific SSH config file and returns the list of hosts,
+// including the hosts of the files it includes
 func ParseSSHConfigFile(configPath string) ([]SSHHost, error) {
-	file, err := os.Open(configPath)
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return parseSSHConfigFile(configPath, make(map[string]bool))
+}
+
+// includePattern returns the path pattern of an Include argument of configPath,
+// with "~/" expanded and relative to the directory of configPath
+func includePattern(configPath, pattern string) (string, error) {
+	if strings.HasPrefix(pattern, "~/") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(homeDir, pattern[2:]), nil
+	}
+	if !filepath.IsAbs(pattern) {
+		return filepath.Join(filepath.Dir(configPath), pattern), nil
+	}
+	return pattern, nil
+}
+
+// resolveIncludePaths expands the patterns of an Include directive into the list
+// of matching files. Relative patterns are resolved against the directory of the
+// including file.
+func resolveIncludePaths(configPath, value string) ([]string, error) {
+	var paths []string
+	for _, pattern := range configArgs(value) {
+		pattern, err := includePattern(configPath, pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		matches, err := Files.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Include pattern '%s': %w", pattern, err)
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// collectConfigFiles returns configPath followed by every file it includes,
+// recursively, in the order they are included
+func collectConfigFiles(configPath string, visited map[string]bool) ([]string, error) {
+	absPath, first, err := enterConfigFile(visited, configPath)
+	if err != nil || !first {
+		return nil, err
+	}
+	defer func() { visited[absPath] = false }()
+
+	content, err := readConfigFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{configPath}
+	for _, line := range strings.Split(string(content), "\n") {
+		keyword, value := splitDirective(line)
+		if value == "" || strings.ToLower(keyword) != "include" {
+			continue
+		}
+		paths, err := resolveIncludePaths(configPath, value)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			included, err := collectConfigFiles(path, visited)
+			if err != nil {
+				return nil, extendIncludeCycle(err, absPath)
+			}
+			files = append(files, included...)
+		}
+	}
+	return files, nil
+}
+
+// parseSSHConfigFile parses configPath, following Include directives. visited holds
+// the absolute paths already parsed, so that each file is read once and include
+// loops fail with an IncludeCycleError; a nil visited map disables Include processing.
+func parseSSHConfigFile(configPath string, visited map[string]bool) ([]SSHHost, error) {
+	var absPath string
+	if visited != nil {
+		var first bool
+		var err error
+		absPath, first, err = enterConfigFile(visited, configPath)
+		if err != nil || !first {
+			return nil, err
+		}
+		defer func() { visited[absPath] = false }()
+	}
+
+	file, _, err := openConfigFile(configPath)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 
+	return scanSSHConfig(file, configPath, absPath, visited)
+}
+
+// ParseSSHConfigReader parses config read from r, e.g. held in memory or read
+// from stdin. Include directives are not followed, since relative patterns
+// have no file to be resolved against, and hosts have no SourceFile.
+func ParseSSHConfigReader(r io.Reader) ([]SSHHost, error) {
+	return scanSSHConfig(r, "", "", nil)
+}
+
+// scanSSHConfig scans config read from r, which comes from configPath (absPath
+// once made absolute) when it is a file. Include directives are followed as
+// described for parseSSHConfigFile.
+func scanSSHConfig(r io.Reader, configPath, absPath string, visited map[string]bool) ([]SSHHost, error) {
 	var hosts []SSHHost
 	var currentHost *SSHHost
-	var pendingTags []string
-	scanner := bufio.NewScanner(file)
+	// Hosts from files included inside a Host block are added once that block ends
+	var includedHosts []SSHHost
+	// Structured comments seen since the last Host line, applied to the next host
+	var pending SSHHost
+	// Modeled directives already set in the current block
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
 
 	for scanner.Scan() {
+		lineNum++
 		line := strings.TrimSpace(scanner.Text())
 
-		// Ignore empty lines
+		// Ignore empty lines, which detach the comments above from the next host
 		if line == "" {
+			pending.Comments = nil
 			continue
 		}
 
@@ -84,41 +765,120 @@ func ParseSSHConfigFile(configPath string) ([]SSHHost, error) {
 				for _, tag := range strings.Split(tagsStr, ",") {
 					tag = strings.TrimSpace(tag)
 					if tag != "" {
-						pendingTags = append(pendingTags, tag)
+						pending.Tags = append(pending.Tags, tag)
 					}
 				}
 			}
 			continue
 		}
 
-		// Ignore other comments
-		if strings.HasPrefix(line, "#") {
+		// Check for meta comment
+		if strings.HasPrefix(line, "# Meta:") {
+			for k, v := range parseMeta(strings.TrimPrefix(line, "# Meta:")) {
+				if pending.Meta == nil {
+					pending.Meta = make(map[string]string)
+				}
+				pending.Meta[k] = v
+			}
 			continue
 		}
 
-		// Split line into words
-		parts := strings.Fields(line)
-		if len(parts) < 2 {
+		// Check for profiles comment, which has the syntax of a meta comment
+		if strings.HasPrefix(line, "# Profiles:") {
+			for k, v := range parseMeta(strings.TrimPrefix(line, "# Profiles:")) {
+				if pending.Profiles == nil {
+					pending.Profiles = make(map[string]string)
+				}
+				pending.Profiles[k] = v
+			}
+			continue
+		}
+
+		// Check for launcher comment
+		if strings.HasPrefix(line, "# Launcher:") {
+			pending.Launcher = strings.TrimSpace(strings.TrimPrefix(line, "# Launcher:"))
+			continue
+		}
+
+		// Check for options comment (options are separated by semicolons, since
+		// values such as algorithm lists contain commas)
+		if strings.HasPrefix(line, "# Options:") {
+			for _, opt := range strings.Split(strings.TrimPrefix(line, "# Options:"), ";") {
+				opt = strings.TrimSpace(opt)
+				if opt != "" {
+					pending.Options = append(pending.Options, opt)
+				}
+			}
+			continue
+		}
+
+		// Check for group comment
+		if strings.HasPrefix(line, "# Group:") {
+			pending.Group = strings.TrimSpace(strings.TrimPrefix(line, "# Group:"))
 			continue
 		}
 
-		key := strings.ToLower(parts[0])
-		value := strings.Join(parts[1:], " ")
+		// Keep other comments for the next host, unless a directive comes first
+		if strings.HasPrefix(line, "#") {
+			pending.Comments = append(pending.Comments, line)
+			continue
+		}
+		if lineKeyword(line) != "host" {
+			pending.Comments = nil
+		}
+
+		keyword, value := splitDirective(line)
+		if value == "" {
+			continue
+		}
+		key := strings.ToLower(keyword)
+
+		if currentHost != nil && key != "host" && key != "match" {
+			currentHost.EndLine = lineNum
+
+			// As in ssh, the first value of a directive within a block wins.
+			// Additional IdentityFile lines are all used by ssh, so they are
+			// kept in Identities after the first one.
+			if modeledDirectives[key] {
+				if seen[key] {
+					if key == "identityfile" {
+						currentHost.Identities = append(currentHost.Identities, value)
+					}
+					continue
+				}
+				seen[key] = true
+			}
+		}
 
 		switch key {
 		case "host":
 			// New host, save previous one if it exists
 			if currentHost != nil {
-				hosts = append(hosts, *currentHost)
+				hosts = append(hosts, splitHostPatterns(*currentHost)...)
 			}
-			// Create new host
-			currentHost = &SSHHost{
-				Name: value,
-				Port: "22",        // Default port
-				Tags: pendingTags, // Assign pending tags to this host
+			hosts = append(hosts, includedHosts...)
+			includedHosts = nil
+			// Create new host, assigning pending structured comments to it
+			host := pending
+			host.Name = strings.Join(configArgs(value), " ")
+			host.Port = "22" // Default port
+			host.SourceFile = configPath
+			host.LineNumber = lineNum
+			host.EndLine = lineNum
+			currentHost = &host
+			// Clear pending comments for next host
+			pending = SSHHost{}
+			seen = make(map[string]bool)
+		case "match":
+			// Directives of a Match block apply conditionally and are not
+			// attributed to any host
+			if currentHost != nil {
+				hosts = append(hosts, splitHostPatterns(*currentHost)...)
 			}
-			// Clear pending tags for next host
-			pendingTags = nil
+			hosts = append(hosts, includedHosts...)
+			includedHosts = nil
+			currentHost = nil
+			pending = SSHHost{}
 		case "hostname":
 			if currentHost != nil {
 				currentHost.Hostname = value
@@ -134,43 +894,183 @@ func ParseSSHConfigFile(configPath string) ([]SSHHost, error) {
 		case "identityfile":
 			if currentHost != nil {
 				currentHost.Identity = value
+				currentHost.Identities = []string{value}
 			}
 		case "proxyjump":
 			if currentHost != nil {
 				currentHost.ProxyJump = value
 			}
+		case "proxycommand":
+			if currentHost != nil {
+				currentHost.ProxyCommand = value
+			}
+		case "controlmaster":
+			if currentHost != nil {
+				currentHost.ControlMaster = value
+			}
+		case "controlpath":
+			if currentHost != nil {
+				currentHost.ControlPath = value
+			}
+		case "ciphers":
+			if currentHost != nil {
+				currentHost.Ciphers = value
+			}
+		case "macs":
+			if currentHost != nil {
+				currentHost.MACs = value
+			}
+		case "kexalgorithms":
+			if currentHost != nil {
+				currentHost.KexAlgorithms = value
+			}
+		case "addressfamily":
+			if currentHost != nil {
+				currentHost.AddressFamily = value
+			}
+		case "bindaddress":
+			if currentHost != nil {
+				currentHost.BindAddress = value
+			}
+		case "forwardx11":
+			if currentHost != nil {
+				currentHost.ForwardX11 = value
+			}
+		case "forwardx11trusted":
+			if currentHost != nil {
+				currentHost.ForwardX11Trusted = value
+			}
+		case "dynamicforward":
+			if currentHost != nil {
+				currentHost.DynamicForwards = append(currentHost.DynamicForwards, value)
+			}
+		case "localforward":
+			if currentHost != nil {
+				currentHost.LocalForwards = append(currentHost.LocalForwards, value)
+			}
+		case "remoteforward":
+			if currentHost != nil {
+				currentHost.RemoteForwards = append(currentHost.RemoteForwards, value)
+			}
+		case "forwardagent":
+			if currentHost != nil {
+				currentHost.ForwardAgent = value
+			}
+		case "serveraliveinterval":
+			if currentHost != nil {
+				currentHost.ServerAliveInterval = value
+			}
+		case "stricthostkeychecking":
+			if currentHost != nil {
+				currentHost.StrictHostKeyChecking = value
+			}
+		case "connecttimeout":
+			if currentHost != nil {
+				currentHost.ConnectTimeout = value
+			}
+		case "include":
+			if visited == nil {
+				continue
+			}
+			paths, err := resolveIncludePaths(configPath, value)
+			if err != nil {
+				return nil, err
+			}
+			for _, path := range paths {
+				included, err := parseSSHConfigFile(path, visited)
+				if err != nil {
+					return nil, extendIncludeCycle(err, absPath)
+				}
+				if currentHost != nil {
+					includedHosts = append(includedHosts, included...)
+				} else {
+					hosts = append(hosts, included...)
+				}
+			}
+		default:
+			// Keep directives we don't model so they can be written back
+			if currentHost != nil {
+				if currentHost.Extra == nil {
+					currentHost.Extra = make(map[string][]string)
+				}
+				currentHost.Extra[keyword] = append(currentHost.Extra[keyword], value)
+			}
 		}
 	}
 
 	// Add the last host if it exists
 	if currentHost != nil {
-		hosts = append(hosts, *currentHost)
+		hosts = append(hosts, splitHostPatterns(*currentHost)...)
 	}
+	hosts = append(hosts, includedHosts...)
 
 	return hosts, scanner.Err()
 }
 
+// AddOptions tunes where AddSSHHostWithOptions writes a new host
+type AddOptions struct {
+	// GroupWithTag inserts the host right after the last host of the config
+	// file sharing one of its tags instead of appending it at the end
+	GroupWithTag bool
+}
+
 // AddSSHHost adds a new SSH host to the config file
 func AddSSHHost(host SSHHost) error {
-	configMutex.Lock()
-	defer configMutex.Unlock()
+	return AddSSHHostWithOptions(host, AddOptions{})
+}
 
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return err
+// tagGroupInsertIndex returns the index of the line after the block of the last
+// host of hosts (parsed from lines) sharing a tag with host
+func tagGroupInsertIndex(lines []string, hosts []SSHHost, host SSHHost) (int, bool) {
+	index, found := 0, false
+	for _, other := range hosts {
+		if !sharesTag(other, host) || other.LineNumber < 1 || other.LineNumber > len(lines) {
+			continue
+		}
+		if end := hostBlockEnd(lines, other.LineNumber-1); end > index {
+			index, found = end, true
+		}
 	}
+	return index, found
+}
 
-	configPath := filepath.Join(homeDir, ".ssh", "config")
+// sharesTag reports whether two hosts have a tag in common
+func sharesTag(a, b SSHHost) bool {
+	for _, tag := range a.Tags {
+		for _, other := range b.Tags {
+			if strings.EqualFold(tag, other) {
+				return true
+			}
+		}
+	}
+	return false
+}
 
-	// Create backup before modification if file exists
-	if _, err := os.Stat(configPath); err == nil {
-		if err := backupConfig(configPath); err != nil {
-			return fmt.Errorf("failed to create backup: %w", err)
+// AddSSHHostWithOptions adds a new SSH host to the config file, or to
+// ManagedFile when set, where insertNewHost puts it
+func AddSSHHostWithOptions(host SSHHost, opts AddOptions) (err error) {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+	if host.Tags, err = normalizeTags(host.Tags); err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			err = recordOperation(OpAdd, host.Name, &host)
 		}
+	}()
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
 	}
 
 	// Check if host already exists
-	exists, err := HostExists(host.Name)
+	exists, err := hostExists(host.Name)
 	if err != nil {
 		return err
 	}
@@ -178,72 +1078,79 @@ func AddSSHHost(host SSHHost) error {
 		return fmt.Errorf("host '%s' already exists", host.Name)
 	}
 
-	// Open file in append mode
-	file, err := os.OpenFile(configPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
-	if err != nil {
+	if configPath, err = newHostFilePath(configPath); err != nil {
 		return err
 	}
-	defer file.Close()
 
-	// Write the configuration
-	_, err = file.WriteString("\n")
-	if err != nil {
+	content, err := readConfigFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
-	// Write tags if present
-	if len(host.Tags) > 0 {
-		_, err = file.WriteString("# Tags: " + strings.Join(host.Tags, ", ") + "\n")
-		if err != nil {
-			return err
+	// Create backup before modification if file exists
+	if err == nil {
+		if err := backupConfig(configPath); err != nil {
+			return fmt.Errorf("failed to create backup: %w", err)
 		}
 	}
 
-	// Write host configuration
-	_, err = file.WriteString(fmt.Sprintf("Host %s\n", host.Name))
-	if err != nil {
-		return err
-	}
-
-	_, err = file.WriteString(fmt.Sprintf("    HostName %s\n", host.Hostname))
+	lines, err := insertNewHost(strings.Split(string(content), "\n"), host, opts)
 	if err != nil {
 		return err
 	}
+	return writeWithSidecarMetadata(func() error {
+		return writeConfigFile(configPath, []byte(strings.Join(lines, "\n")))
+	}, []SSHHost{host})
+}
 
-	if host.User != "" {
-		_, err = file.WriteString(fmt.Sprintf("    User %s\n", host.User))
+// insertNewHost returns the config lines with the block of host added. With
+// opts.GroupWithTag, it goes right after the last host sharing one of its tags.
+// Otherwise it is appended, unless the lines end with Match blocks that may
+// apply to it: as the first value of a setting wins, a host appended below them
+// would have its settings overridden, so it is inserted above them.
+func insertNewHost(lines []string, host SSHHost, opts AddOptions) ([]string, error) {
+	if opts.GroupWithTag && len(host.Tags) > 0 {
+		hosts, err := ParseSSHConfigReader(strings.NewReader(strings.Join(lines, "\n")))
 		if err != nil {
-			return err
+			return nil, err
 		}
-	}
-
-	if host.Port != "" && host.Port != "22" {
-		_, err = file.WriteString(fmt.Sprintf("    Port %s\n", host.Port))
-		if err != nil {
-			return err
+		if err := applySidecarMetadata(hosts); err != nil {
+			return nil, err
 		}
-	}
-
-	if host.Identity != "" {
-		_, err = file.WriteString(fmt.Sprintf("    IdentityFile %s\n", host.Identity))
-		if err != nil {
-			return err
+		if index, found := tagGroupInsertIndex(lines, hosts, host); found {
+			return insertHostLines(lines, index, host), nil
 		}
 	}
 
-	if host.ProxyJump != "" {
-		_, err = file.WriteString(fmt.Sprintf("    ProxyJump %s\n", host.ProxyJump))
-		if err != nil {
-			return err
-		}
+	if index, found := trailingMatchIndex(lines, host); found {
+		return insertHostLines(lines, index, host), nil
 	}
+	return append(append(lines, formatHostBlock(host)...), ""), nil
+}
 
-	return nil
+// insertHostLines returns lines with the block of host inserted before the line
+// at index
+func insertHostLines(lines []string, index int, host SSHHost) []string {
+	block := append([]string{""}, formatHostBlock(host)...)
+	if index < len(lines) && strings.TrimSpace(lines[index]) != "" {
+		block = append(block, "")
+	}
+	if index == 0 || strings.TrimSpace(lines[index-1]) == "" {
+		block = block[1:]
+	}
+	return append(append(append([]string{}, lines[:index]...), block...), lines[index:]...)
 }
 
 // HostExists checks if a host already exists in the config
 func HostExists(hostName string) (bool, error) {
-	hosts, err := ParseSSHConfig()
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return hostExists(hostName)
+}
+
+// hostExists is HostExists for callers already holding configMutex
+func hostExists(hostName string) (bool, error) {
+	hosts, err := parseSSHConfig()
 	if err != nil {
 		return false, err
 	}
@@ -258,7 +1165,14 @@ func HostExists(hostName string) (bool, error) {
 
 // GetSSHHost retrieves a specific host configuration by name
 func GetSSHHost(hostName string) (*SSHHost, error) {
-	hosts, err := ParseSSHConfig()
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return getSSHHost(hostName)
+}
+
+// getSSHHost is GetSSHHost for callers already holding configMutex
+func getSSHHost(hostName string) (*SSHHost, error) {
+	hosts, err := parseSSHConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -271,17 +1185,49 @@ func GetSSHHost(hostName string) (*SSHHost, error) {
 	return nil, fmt.Errorf("host '%s' not found", hostName)
 }
 
-// UpdateSSHHost updates an existing SSH host configuration
-func UpdateSSHHost(oldName string, newHost SSHHost) error {
+// UpdateSSHHost updates an existing SSH host configuration. The block is
+// rewritten to match newHost: an empty setting, such as an empty Identity,
+// removes the directive rather than leaving it unchanged.
+func UpdateSSHHost(oldName string, newHost SSHHost) (err error) {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+	if newHost.Tags, err = normalizeTags(newHost.Tags); err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			err = recordOperation(OpUpdate, oldName, &newHost)
+		}
+	}()
+
 	configMutex.Lock()
 	defer configMutex.Unlock()
+	return updateSSHHost(oldName, newHost)
+}
 
-	homeDir, err := os.UserHomeDir()
+// updateSSHHost is UpdateSSHHost for callers already holding configMutex, which
+// normalize the tags of newHost and record the operation
+func updateSSHHost(oldName string, newHost SSHHost) error {
+	configPath, err := getConfigPath()
 	if err != nil {
 		return err
 	}
 
-	configPath := filepath.Join(homeDir, ".ssh", "config")
+	// Renaming must not create a duplicate host
+	if newHost.Name != oldName {
+		exists, err := hostExists(newHost.Name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("host '%s' already exists", newHost.Name)
+		}
+	}
+
+	if configPath, err = hostFilePath(configPath, oldName); err != nil {
+		return err
+	}
 
 	// Create backup before modification
 	if err := backupConfig(configPath); err != nil {
@@ -289,108 +1235,45 @@ func UpdateSSHHost(oldName string, newHost SSHHost) error {
 	}
 
 	// Read the current config
-	content, err := os.ReadFile(configPath)
+	content, err := readConfigFile(configPath)
 	if err != nil {
 		return err
 	}
 
 	lines := strings.Split(string(content), "\n")
-	var newLines []string
-	i := 0
-	hostFound := false
-
-	for i < len(lines) {
-		line := strings.TrimSpace(lines[i])
-
-		// Check for tags comment followed by Host
-		if strings.HasPrefix(line, "# Tags:") && i+1 < len(lines) {
-			nextLine := strings.TrimSpace(lines[i+1])
-			if nextLine == "Host "+oldName {
-				// Found the host to update, skip the old configuration
-				hostFound = true
-
-				// Skip until we find the end of this host block (empty line or next Host)
-				i += 2 // Skip tags and Host line
-				for i < len(lines) && strings.TrimSpace(lines[i]) != "" && !strings.HasPrefix(strings.TrimSpace(lines[i]), "Host ") {
-					i++
-				}
-
-				// Insert new configuration at this position
-				newLines = append(newLines, "")
-				if len(newHost.Tags) > 0 {
-					newLines = append(newLines, "# Tags: "+strings.Join(newHost.Tags, ", "))
-				}
-				newLines = append(newLines, "Host "+newHost.Name)
-				newLines = append(newLines, "    HostName "+newHost.Hostname)
-				if newHost.User != "" {
-					newLines = append(newLines, "    User "+newHost.User)
-				}
-				if newHost.Port != "" && newHost.Port != "22" {
-					newLines = append(newLines, "    Port "+newHost.Port)
-				}
-				if newHost.Identity != "" {
-					newLines = append(newLines, "    IdentityFile "+newHost.Identity)
-				}
-
-				continue
-			}
-		}
-
-		// Check for Host line without tags
-		if strings.HasPrefix(line, "Host ") && strings.Fields(line)[1] == oldName {
-			hostFound = true
-
-			// Skip until we find the end of this host block
-			i++ // Skip Host line
-			for i < len(lines) && strings.TrimSpace(lines[i]) != "" && !strings.HasPrefix(strings.TrimSpace(lines[i]), "Host ") {
-				i++
-			}
-
-			// Insert new configuration
-			newLines = append(newLines, "")
-			if len(newHost.Tags) > 0 {
-				newLines = append(newLines, "# Tags: "+strings.Join(newHost.Tags, ", "))
-			}
-			newLines = append(newLines, "Host "+newHost.Name)
-			newLines = append(newLines, "    HostName "+newHost.Hostname)
-			if newHost.User != "" {
-				newLines = append(newLines, "    User "+newHost.User)
-			}
-			if newHost.Port != "" && newHost.Port != "22" {
-				newLines = append(newLines, "    Port "+newHost.Port)
-			}
-			if newHost.Identity != "" {
-				newLines = append(newLines, "    IdentityFile "+newHost.Identity)
-			}
-
-			continue
-		}
-
-		// Keep other lines as-is
-		newLines = append(newLines, lines[i])
-		i++
-	}
-
+	newLines, hostFound := replaceHostBlock(lines, oldName, newHost)
 	if !hostFound {
 		return fmt.Errorf("host '%s' not found", oldName)
 	}
 
 	// Write back to file
 	newContent := strings.Join(newLines, "\n")
-	return os.WriteFile(configPath, []byte(newContent), 0600)
+	return writeWithSidecarMetadata(func() error {
+		return writeConfigFile(configPath, []byte(newContent))
+	}, []SSHHost{newHost}, oldName)
 }
 
 // DeleteSSHHost removes an SSH host configuration from the config file
-func DeleteSSHHost(hostName string) error {
+func DeleteSSHHost(hostName string) (err error) {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+	defer func() {
+		if err == nil {
+			err = recordOperation(OpDelete, hostName, nil)
+		}
+	}()
+
 	configMutex.Lock()
 	defer configMutex.Unlock()
 
-	homeDir, err := os.UserHomeDir()
+	configPath, err := getConfigPath()
 	if err != nil {
 		return err
 	}
-
-	configPath := filepath.Join(homeDir, ".ssh", "config")
+	if configPath, err = hostFilePath(configPath, hostName); err != nil {
+		return err
+	}
 
 	// Create backup before modification
 	if err := backupConfig(configPath); err != nil {
@@ -398,73 +1281,49 @@ func DeleteSSHHost(hostName string) error {
 	}
 
 	// Read the current config
-	content, err := os.ReadFile(configPath)
+	content, err := readConfigFile(configPath)
 	if err != nil {
 		return err
 	}
 
 	lines := strings.Split(string(content), "\n")
-	var newLines []string
-	i := 0
-	hostFound := false
-
-	for i < len(lines) {
-		line := strings.TrimSpace(lines[i])
-
-		// Check for tags comment followed by Host
-		if strings.HasPrefix(line, "# Tags:") && i+1 < len(lines) {
-			nextLine := strings.TrimSpace(lines[i+1])
-			if nextLine == "Host "+hostName {
-				// Found the host to delete, skip the configuration
-				hostFound = true
-
-				// Skip tags comment and Host line
-				i += 2
-
-				// Skip until we find the end of this host block (empty line or next Host)
-				for i < len(lines) && strings.TrimSpace(lines[i]) != "" && !strings.HasPrefix(strings.TrimSpace(lines[i]), "Host ") {
-					i++
-				}
-
-				// Skip the empty line after the host block if it exists
-				if i < len(lines) && strings.TrimSpace(lines[i]) == "" {
-					i++
-				}
-
-				continue
-			}
-		}
-
-		// Check for Host line without tags
-		if strings.HasPrefix(line, "Host ") && strings.Fields(line)[1] == hostName {
-			hostFound = true
-
-			// Skip Host line
-			i++
-
-			// Skip until we find the end of this host block
-			for i < len(lines) && strings.TrimSpace(lines[i]) != "" && !strings.HasPrefix(strings.TrimSpace(lines[i]), "Host ") {
-				i++
-			}
+	newLines, hostFound := removeHostBlock(lines, hostName)
+	if !hostFound {
+		return fmt.Errorf("host '%s' not found", hostName)
+	}
 
-			// Skip the empty line after the host block if it exists
-			if i < len(lines) && strings.TrimSpace(lines[i]) == "" {
-				i++
-			}
+	// Write back to file
+	newContent := strings.Join(newLines, "\n")
+	return writeWithSidecarMetadata(func() error {
+		return writeConfigFile(configPath, []byte(newContent))
+	}, nil, hostName)
+}
 
-			continue
+// parseMeta parses the "key=value, key=value" payload of a "# Meta:" comment
+func parseMeta(s string) map[string]string {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		key, value, _ := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if key != "" {
+			meta[key] = strings.TrimSpace(value)
 		}
-
-		// Keep other lines as-is
-		newLines = append(newLines, lines[i])
-		i++
 	}
+	return meta
+}
 
-	if !hostFound {
-		return fmt.Errorf("host '%s' not found", hostName)
+// formatMeta renders meta as the payload of a "# Meta:" comment, with keys
+// sorted so that the output is stable
+func formatMeta(meta map[string]string) string {
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 
-	// Write back to file
-	newContent := strings.Join(newLines, "\n")
-	return os.WriteFile(configPath, []byte(newContent), 0600)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+meta[k])
+	}
+	return strings.Join(pairs, ", ")
 }
@@ -0,0 +1,762 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// setForTest sets *v to value for the duration of the test
+func setForTest[T any](t testing.TB, v *T, value T) {
+	t.Helper()
+	old := *v
+	*v = value
+	t.Cleanup(func() { *v = old })
+}
+
+// useTestConfig points the package at a config holding content, in a temporary
+// directory that is also HOME, and returns its path
+func useTestConfig(t testing.TB, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv(configPathEnv, "")
+
+	path := filepath.Join(dir, "config")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	setForTest(t, &ConfigPath, path)
+	setForTest(t, &ManagedFile, "")
+	setForTest(t, &MetadataMode, MetadataInComments)
+	setForTest(t, &ReadOnly, false)
+	return path
+}
+
+// readTestFile returns the content of path
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// mustParse parses content with ParseSSHConfigReader
+func mustParse(t *testing.T, content string) []SSHHost {
+	t.Helper()
+	hosts, err := ParseSSHConfigReader(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hosts
+}
+
+// hostNamed returns the host called name, failing the test if it is missing
+func hostNamed(t *testing.T, hosts []SSHHost, name string) SSHHost {
+	t.Helper()
+	for _, host := range hosts {
+		if host.Name == name {
+			return host
+		}
+	}
+	t.Fatalf("host %q not found", name)
+	return SSHHost{}
+}
+
+const includeInBlockConfig = `Host web
+    HostName web.example
+    Include extra.conf
+    User foo
+
+Host db
+    HostName db.example
+`
+
+func TestWriteHostWithIncludeInBlock(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func() error
+		want  string
+	}{
+		{
+			name:  "delete",
+			write: func() error { return DeleteSSHHost("web") },
+			want:  "Host db\n    HostName db.example\n",
+		},
+		{
+			name:  "delete streaming",
+			write: func() error { return DeleteSSHHostStreaming("web") },
+			want:  "Host db\n    HostName db.example\n",
+		},
+		{
+			name: "update",
+			write: func() error {
+				return UpdateSSHHost("web", SSHHost{Name: "web", Hostname: "web.example", User: "bar", Port: "22"})
+			},
+			want: "Host web\n    HostName web.example\n    Include extra.conf\n    User bar\n\nHost db\n    HostName db.example\n",
+		},
+		{
+			name: "update streaming",
+			write: func() error {
+				return UpdateSSHHostStreaming("web", SSHHost{Name: "web", Hostname: "web.example", User: "bar", Port: "22"})
+			},
+			want: "Host web\n    HostName web.example\n    Include extra.conf\n    User bar\n\nHost db\n    HostName db.example\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useTestConfig(t, includeInBlockConfig)
+			if err := tt.write(); err != nil {
+				t.Fatal(err)
+			}
+
+			got := readTestFile(t, path)
+			if got != tt.want {
+				t.Errorf("config = %q, want %q", got, tt.want)
+			}
+			if db := hostNamed(t, mustParse(t, got), "db"); db.User != "" {
+				t.Errorf("db gained User %q from the block of web", db.User)
+			}
+		})
+	}
+}
+
+func TestSplitDirective(t *testing.T) {
+	tests := []struct {
+		line        string
+		wantKeyword string
+		wantValue   string
+	}{
+		{"HostName example.com", "HostName", "example.com"},
+		{"    hostname   example.com  ", "hostname", "example.com"},
+		{"HostName=example.com", "HostName", "example.com"},
+		{"HostName = example.com", "HostName", "example.com"},
+		{"\tUser\tdeploy", "User", "deploy"},
+		{`ProxyCommand "ssh -W %h:%p bastion"`, "ProxyCommand", `"ssh -W %h:%p bastion"`},
+		{"ProxyCommand=ssh  -W %h:%p bastion", "ProxyCommand", "ssh  -W %h:%p bastion"},
+		{"Host", "Host", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			keyword, value := splitDirective(tt.line)
+			if keyword != tt.wantKeyword || value != tt.wantValue {
+				t.Errorf("splitDirective(%q) = %q, %q, want %q, %q", tt.line, keyword, value, tt.wantKeyword, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestConfigArgs(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"web db", []string{"web", "db"}},
+		{"  web \t db  ", []string{"web", "db"}},
+		{`"my host" db`, []string{"my host", "db"}},
+		{`~/.ssh/"my key"`, []string{"~/.ssh/my key"}},
+		{`""`, []string{""}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := configArgs(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("configArgs(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseKeywordsAndQuotes(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		host   string
+		check  func(SSHHost) (got, want string)
+	}{
+		{
+			name:   "lowercase keywords",
+			config: "host web\n    hostname web.example\n    user deploy\n",
+			host:   "web",
+			check:  func(h SSHHost) (string, string) { return h.Hostname + " " + h.User, "web.example deploy" },
+		},
+		{
+			name:   "mixed case keywords",
+			config: "HOST web\n    hostNAME web.example\n    USER deploy\n",
+			host:   "web",
+			check:  func(h SSHHost) (string, string) { return h.Hostname + " " + h.User, "web.example deploy" },
+		},
+		{
+			name:   "key=value",
+			config: "Host=web\n    HostName=web.example\n    Port = 2222\n",
+			host:   "web",
+			check:  func(h SSHHost) (string, string) { return h.Hostname + " " + h.Port, "web.example 2222" },
+		},
+		{
+			name:   "name keeps its case",
+			config: "host WebServer\n    HostName web.example\n",
+			host:   "WebServer",
+			check:  func(h SSHHost) (string, string) { return h.Hostname, "web.example" },
+		},
+		{
+			name:   "quoted name",
+			config: "Host \"web\"\n    HostName web.example\n",
+			host:   "web",
+			check:  func(h SSHHost) (string, string) { return h.Hostname, "web.example" },
+		},
+		{
+			name:   "quoted proxy command",
+			config: "Host web\n    ProxyCommand \"ssh -W %h:%p bastion\"\n",
+			host:   "web",
+			check:  func(h SSHHost) (string, string) { return h.ProxyCommand, `"ssh -W %h:%p bastion"` },
+		},
+		{
+			name:   "unquoted proxy command",
+			config: "Host web\n    ProxyCommand ssh -W %h:%p bastion\n",
+			host:   "web",
+			check:  func(h SSHHost) (string, string) { return h.ProxyCommand, "ssh -W %h:%p bastion" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := hostNamed(t, mustParse(t, tt.config), tt.host)
+			if got, want := tt.check(host); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestQuotedProxyCommandRoundTrip(t *testing.T) {
+	const config = "Host web\n    HostName web.example\n    ProxyCommand \"ssh -W %h:%p bastion\"\n"
+	path := useTestConfig(t, config)
+
+	host, err := GetSSHHost("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateSSHHost("web", *host); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, path); got != config {
+		t.Errorf("config = %q, want %q", got, config)
+	}
+}
+
+func TestAlgorithmDirectives(t *testing.T) {
+	tests := []struct {
+		name                string
+		ciphers, macs, kexs string
+	}{
+		{"lists", "aes256-gcm@openssh.com,chacha20-poly1305@openssh.com", "hmac-sha2-512-etm@openssh.com,hmac-sha2-256", "curve25519-sha256,diffie-hellman-group16-sha512"},
+		{"appended", "+aes128-cbc", "+hmac-sha1", "+diffie-hellman-group1-sha1"},
+		{"removed", "-3des-cbc", "-hmac-md5", "-diffie-hellman-group14-sha1"},
+		{"preferred", "^aes128-ctr", "^umac-64@openssh.com", "^sntrup761x25519-sha512@openssh.com"},
+		{"unknown to us", "future-cipher@example.com", "future-mac", "mlkem768x25519-sha256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := "Host legacy\n    HostName 10.0.0.1\n    Ciphers " + tt.ciphers + "\n    MACs " + tt.macs + "\n    KexAlgorithms " + tt.kexs + "\n"
+			path := useTestConfig(t, config)
+
+			host, err := GetSSHHost("legacy")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if host.Ciphers != tt.ciphers || host.MACs != tt.macs || host.KexAlgorithms != tt.kexs {
+				t.Fatalf("parsed %q, %q, %q", host.Ciphers, host.MACs, host.KexAlgorithms)
+			}
+
+			host.Port = "2222"
+			if err := UpdateSSHHost("legacy", *host); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := readTestFile(t, path), config+"    Port 2222\n"; got != want {
+				t.Errorf("after update config = %q, want %q", got, want)
+			}
+
+			added := SSHHost{Name: "new", Hostname: "10.0.0.2", Ciphers: tt.ciphers, MACs: tt.macs, KexAlgorithms: tt.kexs}
+			if err := AddSSHHost(added); err != nil {
+				t.Fatal(err)
+			}
+			hosts, err := ParseSSHConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hostNamed(t, hosts, "new"); got.Ciphers != tt.ciphers || got.MACs != tt.macs || got.KexAlgorithms != tt.kexs {
+				t.Errorf("added host has %q, %q, %q", got.Ciphers, got.MACs, got.KexAlgorithms)
+			}
+		})
+	}
+}
+
+func TestParseFirstValueWins(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		check  func(SSHHost) (got, want any)
+	}{
+		{
+			name:   "duplicated User",
+			config: "Host web\n    User first\n    HostName web.example\n    User second\n",
+			check:  func(h SSHHost) (any, any) { return h.User, "first" },
+		},
+		{
+			name:   "duplicated with other case and syntax",
+			config: "Host web\n    Port 2200\n    port=2201\n    PORT 2202\n",
+			check:  func(h SSHHost) (any, any) { return h.Port, "2200" },
+		},
+		{
+			name:   "duplicated ProxyJump",
+			config: "Host web\n    ProxyJump bastion1\n    ProxyJump bastion2\n",
+			check:  func(h SSHHost) (any, any) { return h.ProxyJump, "bastion1" },
+		},
+		{
+			name:   "every IdentityFile kept",
+			config: "Host web\n    IdentityFile ~/.ssh/a\n    IdentityFile ~/.ssh/b\n",
+			check: func(h SSHHost) (any, any) {
+				return []string{h.Identity, h.Identities[0], h.Identities[1]}, []string{"~/.ssh/a", "~/.ssh/a", "~/.ssh/b"}
+			},
+		},
+		{
+			name:   "every LocalForward kept",
+			config: "Host web\n    LocalForward 8080 localhost:80\n    LocalForward 8443 localhost:443\n",
+			check: func(h SSHHost) (any, any) {
+				return h.LocalForwards, []string{"8080 localhost:80", "8443 localhost:443"}
+			},
+		},
+		{
+			name:   "values of the next block are its own",
+			config: "Host web\n    User first\n\nHost db\n    User second\n    User third\n",
+			check:  func(h SSHHost) (any, any) { return h.User, "first" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := hostNamed(t, mustParse(t, tt.config), "web")
+			if got, want := tt.check(host); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+
+	db := hostNamed(t, mustParse(t, tests[len(tests)-1].config), "db")
+	if db.User != "second" {
+		t.Errorf("db User = %q, want second", db.User)
+	}
+}
+
+func TestCheckDirectivesRepeated(t *testing.T) {
+	issues := checkDirectives("config", []byte("Host web\n    User first\n    user=second\n    IdentityFile ~/.ssh/a\n    IdentityFile ~/.ssh/b\n"), new([]string))
+	if len(issues) != 1 || issues[0].Line != 3 || issues[0].Message != "user is already set on line 2, ssh uses the first value" {
+		t.Errorf("checkDirectives() = %+v, want one warning on line 3", issues)
+	}
+}
+
+func TestUpdateDropsShadowedValues(t *testing.T) {
+	path := useTestConfig(t, "Host web\n    User first\n    HostName web.example\n    User second\n")
+	host, err := GetSSHHost("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host.User = "new"
+	if err := UpdateSSHHost("web", *host); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readTestFile(t, path), "Host web\n    User new\n    HostName web.example\n"; got != want {
+		t.Errorf("config = %q, want %q", got, want)
+	}
+}
+
+const bannerConfig = `# Shared settings, not about web
+
+# Production web server
+# Owner: web team
+# Tags: web
+Host web
+    HostName web.example
+
+# Database
+Host db
+    HostName db.example
+`
+
+func TestHostComments(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func() error
+		want  string
+	}{
+		{
+			name: "update keeps banner",
+			write: func() error {
+				return UpdateSSHHost("web", SSHHost{Name: "web", Hostname: "web2.example", Tags: []string{"web"}})
+			},
+			want: strings.Replace(bannerConfig, "web.example", "web2.example", 1),
+		},
+		{
+			name: "update replaces banner",
+			write: func() error {
+				return UpdateSSHHost("web", SSHHost{Name: "web", Hostname: "web.example", Tags: []string{"web"}, Comments: []string{"# Retired soon"}})
+			},
+			want: strings.Replace(bannerConfig, "# Production web server\n# Owner: web team\n", "# Retired soon\n", 1),
+		},
+		{
+			name: "update removes banner",
+			write: func() error {
+				return UpdateSSHHost("web", SSHHost{Name: "web", Hostname: "web.example", Tags: []string{"web"}, Comments: []string{}})
+			},
+			want: strings.Replace(bannerConfig, "# Production web server\n# Owner: web team\n", "", 1),
+		},
+		{
+			name:  "delete removes banner",
+			write: func() error { return DeleteSSHHost("web") },
+			want:  "# Shared settings, not about web\n\n# Database\nHost db\n    HostName db.example\n",
+		},
+		{
+			name:  "delete streaming removes banner",
+			write: func() error { return DeleteSSHHostStreaming("web") },
+			want:  "# Shared settings, not about web\n\n# Database\nHost db\n    HostName db.example\n",
+		},
+		{
+			name:  "delete last host removes banner",
+			write: func() error { return DeleteSSHHost("db") },
+			want:  strings.TrimSuffix(bannerConfig, "\n# Database\nHost db\n    HostName db.example\n"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useTestConfig(t, bannerConfig)
+
+			hosts, err := ParseSSHConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := hostNamed(t, hosts, "web").Comments, []string{"# Production web server", "# Owner: web team"}; !reflect.DeepEqual(got, want) {
+				t.Fatalf("web Comments = %q, want %q", got, want)
+			}
+
+			if err := tt.write(); err != nil {
+				t.Fatal(err)
+			}
+			if got := readTestFile(t, path); got != tt.want {
+				t.Errorf("config = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateProxyJump(t *testing.T) {
+	const config = "Host web\n    HostName 10.0.0.5\n    User deploy\n    ProxyJump bastion\n    Port 22\n\nHost bastion\n    HostName bastion.example\n"
+	tests := []struct {
+		name          string
+		change        func(*SSHHost)
+		streaming     bool
+		wantProxyJump string
+		wantConfig    string
+	}{
+		{
+			name:          "unrelated field",
+			change:        func(h *SSHHost) { h.Port = "2222" },
+			wantProxyJump: "bastion",
+			wantConfig:    strings.Replace(config, "Port 22\n", "Port 2222\n", 1),
+		},
+		{
+			name:          "unrelated field streaming",
+			change:        func(h *SSHHost) { h.Port = "2222" },
+			streaming:     true,
+			wantProxyJump: "bastion",
+			wantConfig:    strings.Replace(config, "Port 22\n", "Port 2222\n", 1),
+		},
+		{
+			name:          "renamed",
+			change:        func(h *SSHHost) { h.Name = "frontend" },
+			wantProxyJump: "bastion",
+			wantConfig:    strings.Replace(config, "Host web\n", "Host frontend\n", 1),
+		},
+		{
+			name:          "changed",
+			change:        func(h *SSHHost) { h.ProxyJump = "deploy@bastion:2222,inner" },
+			wantProxyJump: "deploy@bastion:2222,inner",
+			wantConfig:    strings.Replace(config, "ProxyJump bastion\n", "ProxyJump deploy@bastion:2222,inner\n", 1),
+		},
+		{
+			name:       "cleared",
+			change:     func(h *SSHHost) { h.ProxyJump = "" },
+			wantConfig: strings.Replace(config, "    ProxyJump bastion\n", "", 1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useTestConfig(t, config)
+			host, err := GetSSHHost("web")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.change(host)
+
+			if tt.streaming {
+				err = UpdateSSHHostStreaming("web", *host)
+			} else {
+				err = UpdateSSHHost("web", *host)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := readTestFile(t, path)
+			if got != tt.wantConfig {
+				t.Errorf("config = %q, want %q", got, tt.wantConfig)
+			}
+			if updated := hostNamed(t, mustParse(t, got), host.Name); updated.ProxyJump != tt.wantProxyJump {
+				t.Errorf("ProxyJump = %q, want %q", updated.ProxyJump, tt.wantProxyJump)
+			}
+		})
+	}
+}
+
+func TestAddProxyJump(t *testing.T) {
+	path := useTestConfig(t, "")
+	if err := AddSSHHost(SSHHost{Name: "web", Hostname: "10.0.0.5", ProxyJump: "bastion"}); err != nil {
+		t.Fatal(err)
+	}
+	if web := hostNamed(t, mustParse(t, readTestFile(t, path)), "web"); web.ProxyJump != "bastion" {
+		t.Errorf("ProxyJump = %q, want bastion", web.ProxyJump)
+	}
+}
+
+const exoticConfig = `Host web
+    HostName web.example
+    # Deploy key, rotated yearly
+    SetEnv DEPLOY_ENV=prod "GREETING=hello world"
+    LocalForward 8080 localhost:80
+	SendEnv=LANG LC_*
+    User deploy
+    ForwardAgent yes
+    RequestTTY force
+
+Host db
+    HostName db.example
+    CanonicalizeHostname always
+`
+
+func TestUpdateKeepsUnknownLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*SSHHost)
+		want   string
+	}{
+		{
+			name:   "sibling field",
+			change: func(h *SSHHost) { h.User = "admin" },
+			want:   strings.Replace(exoticConfig, "User deploy", "User admin", 1),
+		},
+		{
+			name:   "added field",
+			change: func(h *SSHHost) { h.Port = "2222" },
+			want:   strings.Replace(exoticConfig, "    RequestTTY force\n", "    RequestTTY force\n    Port 2222\n", 1),
+		},
+		{
+			name:   "nil Extra",
+			change: func(h *SSHHost) { h.Extra = nil; h.User = "admin" },
+			want:   strings.Replace(exoticConfig, "User deploy", "User admin", 1),
+		},
+		{
+			name:   "directive dropped from Extra",
+			change: func(h *SSHHost) { delete(h.Extra, "RequestTTY") },
+			want:   strings.Replace(exoticConfig, "    RequestTTY force\n", "", 1),
+		},
+		{
+			name:   "unchanged",
+			change: func(h *SSHHost) {},
+			want:   exoticConfig,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useTestConfig(t, exoticConfig)
+			host, err := GetSSHHost("web")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.change(host)
+
+			if err := UpdateSSHHost("web", *host); err != nil {
+				t.Fatal(err)
+			}
+			if got := readTestFile(t, path); got != tt.want {
+				t.Errorf("config = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteKeepsOtherBlocks(t *testing.T) {
+	web := strings.TrimSuffix(exoticConfig, "\nHost db\n    HostName db.example\n    CanonicalizeHostname always\n")
+	tests := []struct {
+		name  string
+		write func() error
+		want  string
+	}{
+		{"delete", func() error { return DeleteSSHHost("db") }, web},
+		{"delete streaming", func() error { return DeleteSSHHostStreaming("db") }, web},
+		{"delete first", func() error { return DeleteSSHHost("web") }, "Host db\n    HostName db.example\n    CanonicalizeHostname always\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useTestConfig(t, exoticConfig)
+			if err := tt.write(); err != nil {
+				t.Fatal(err)
+			}
+			if got := readTestFile(t, path); got != tt.want {
+				t.Errorf("config = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForwardX11(t *testing.T) {
+	const config = "Host gui\n    HostName gui.example\n    ForwardX11 yes\n    ForwardX11Trusted no\n"
+	path := useTestConfig(t, config)
+
+	host, err := GetSSHHost("gui")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host.ForwardX11 != "yes" || host.ForwardX11Trusted != "no" {
+		t.Fatalf("parsed ForwardX11 %q and ForwardX11Trusted %q", host.ForwardX11, host.ForwardX11Trusted)
+	}
+
+	host.User = "me"
+	if err := UpdateSSHHost("gui", *host); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readTestFile(t, path), config+"    User me\n"; got != want {
+		t.Errorf("config = %q, want %q", got, want)
+	}
+
+	host.ForwardX11Trusted = "yes"
+	if err := UpdateSSHHost("gui", *host); err != nil {
+		t.Fatal(err)
+	}
+	gui := hostNamed(t, mustParse(t, readTestFile(t, path)), "gui")
+	if gui.ForwardX11 != "yes" || gui.ForwardX11Trusted != "yes" {
+		t.Errorf("after update ForwardX11 %q and ForwardX11Trusted %q, want yes and yes", gui.ForwardX11, gui.ForwardX11Trusted)
+	}
+}
+
+func TestValidateForwardX11(t *testing.T) {
+	tests := []struct {
+		value      string
+		wantIssues int // Both directives are reported when the value is invalid
+	}{
+		{"yes", 0},
+		{"no", 0},
+		{"YES", 0},
+		{"maybe", 2},
+		{"1", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			path := useTestConfig(t, "Host gui\n    HostName gui.example\n    ForwardX11 "+tt.value+"\n    ForwardX11Trusted "+tt.value+"\n")
+			issues, err := ValidateConfigFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var messages []string
+			for _, issue := range issues {
+				if strings.Contains(issue.Message, "ForwardX11") {
+					messages = append(messages, issue.Message)
+				}
+			}
+			if len(messages) != tt.wantIssues {
+				t.Errorf("ForwardX11 issues = %q, want %d", messages, tt.wantIssues)
+			}
+		})
+	}
+}
+
+const detachedTagsConfig = `Host bastion
+    HostName bastion.example
+
+# Tags: web, prod
+
+# Front end
+Host web
+    HostName web.example
+
+Host db
+    HostName db.example
+`
+
+func TestDetachedTagComment(t *testing.T) {
+	const withoutWeb = "Host bastion\n    HostName bastion.example\n\nHost db\n    HostName db.example\n"
+	tests := []struct {
+		name  string
+		write func() error
+		want  string
+	}{
+		{
+			name:  "delete",
+			write: func() error { return DeleteSSHHost("web") },
+			want:  withoutWeb,
+		},
+		{
+			name:  "delete streaming",
+			write: func() error { return DeleteSSHHostStreaming("web") },
+			want:  withoutWeb,
+		},
+		{
+			name: "update",
+			write: func() error {
+				return UpdateSSHHost("web", SSHHost{Name: "web", Hostname: "web2.example", Tags: []string{"web"}})
+			},
+			want: "Host bastion\n    HostName bastion.example\n\n# Front end\n# Tags: web\nHost web\n    HostName web2.example\n\nHost db\n    HostName db.example\n",
+		},
+		{
+			name: "update streaming",
+			write: func() error {
+				return UpdateSSHHostStreaming("web", SSHHost{Name: "web", Hostname: "web2.example", Tags: []string{"web"}})
+			},
+			want: "Host bastion\n    HostName bastion.example\n\n# Front end\n# Tags: web\nHost web\n    HostName web2.example\n\nHost db\n    HostName db.example\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useTestConfig(t, detachedTagsConfig)
+			if web := hostNamed(t, mustParse(t, detachedTagsConfig), "web"); !reflect.DeepEqual(web.Tags, []string{"web", "prod"}) {
+				t.Fatalf("web Tags = %q, want web and prod", web.Tags)
+			}
+
+			if err := tt.write(); err != nil {
+				t.Fatal(err)
+			}
+			got := readTestFile(t, path)
+			if got != tt.want {
+				t.Errorf("config = %q, want %q", got, tt.want)
+			}
+			for _, host := range mustParse(t, got) {
+				if host.Name != "web" && len(host.Tags) > 0 {
+					t.Errorf("host %q gained Tags %q", host.Name, host.Tags)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// errBlockNotFound aborts the write of streamHostBlock when the block is missing
+var errBlockNotFound = errors.New("host block not found")
+
+// streamHostBlock rewrites configPath line by line into a temporary file that
+// replaces it, passing the block of hostName, along with the comments and empty
+// lines above it and the empty lines and comments that follow it, to
+// transform. Only that part of the file is held in memory. The file is left
+// untouched, and false returned, when the block is missing.
+func streamHostBlock(configPath, hostName string, transform func([]string) []string) (bool, error) {
+	src, encoding, err := openConfigFile(configPath)
+	if err != nil {
+		return false, err
+	}
+	defer src.Close()
+
+	err = atomicWrite(configPath, 0600, func(w io.Writer) error {
+		if encoding != "" {
+			w = &utf16Writer{w: w, bigEndian: encoding == "UTF-16BE"}
+		}
+		r := bufio.NewReader(src)
+		out := bufio.NewWriter(w)
+		first := true
+		emit := func(lines ...string) {
+			for _, line := range lines {
+				if !first {
+					out.WriteByte('\n')
+				}
+				out.WriteString(line)
+				first = false
+			}
+		}
+
+		// held holds the comments, and empty lines between them, that may open
+		// the block, then the block
+		var held []string
+		inBlock, done := false, false
+		for {
+			line, readErr := r.ReadString('\n')
+			if readErr != nil && readErr != io.EOF {
+				return readErr
+			}
+			line = strings.TrimSuffix(line, "\n")
+			trimmed := strings.TrimSpace(line)
+
+			switch {
+			case done:
+				emit(line)
+			case inBlock && !isBlockBoundary(line):
+				held = append(held, line)
+			case inBlock:
+				emit(transform(held)...)
+				emit(line)
+				held, inBlock, done = nil, false, true
+			case strings.HasPrefix(trimmed, "#"), trimmed == "" && len(held) > 0:
+				held = append(held, line)
+			case isHostLine(trimmed, hostName):
+				held = append(held, line)
+				inBlock = true
+			default:
+				emit(held...)
+				emit(line)
+				held = nil
+			}
+
+			if readErr == io.EOF {
+				break
+			}
+		}
+
+		switch {
+		case inBlock:
+			emit(transform(held)...)
+		case !done:
+			return errBlockNotFound
+		}
+		return out.Flush()
+	})
+
+	if errors.Is(err, errBlockNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// streamHostFilePath is hostFilePath for the streaming writers: the config and
+// the files it includes are scanned line by line for the Host line of hostName,
+// in the order ssh reads them, instead of being parsed into memory
+func streamHostFilePath(configPath, hostName string) (string, error) {
+	files, err := streamHostFiles(configPath, hostName)
+	if path, ok := files[hostName]; ok {
+		return path, err
+	}
+	return configPath, err
+}
+
+// streamHostFiles returns the file defining each of names among configPath
+// and the files it includes, scanned line by line in a single pass. Names no
+// file defines are left out.
+func streamHostFiles(configPath string, names ...string) (map[string]string, error) {
+	found := make(map[string]string, len(names))
+	err := findHostFiles(configPath, names, found, make(map[string]bool))
+	if os.IsNotExist(err) {
+		return found, nil
+	}
+	return found, err
+}
+
+// findHostFiles adds to found the file defining each of names among
+// configPath and the files it includes, keeping the first one in the order
+// ssh reads them, until all are found. visited detects include loops as in
+// collectConfigFiles.
+func findHostFiles(configPath string, names []string, found map[string]string, visited map[string]bool) error {
+	absPath, first, err := enterConfigFile(visited, configPath)
+	if err != nil || !first {
+		return err
+	}
+	defer func() { visited[absPath] = false }()
+
+	src, _, err := openConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	scanner := bufio.NewScanner(src)
+	for scanner.Scan() && len(found) < len(names) {
+		line := strings.TrimSpace(scanner.Text())
+		for _, name := range names {
+			if _, ok := found[name]; !ok && isHostLine(line, name) {
+				found[name] = configPath
+			}
+		}
+
+		keyword, value := splitDirective(line)
+		if value == "" || strings.ToLower(keyword) != "include" {
+			continue
+		}
+		paths, err := resolveIncludePaths(configPath, value)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			if err := findHostFiles(path, names, found, visited); err != nil {
+				return extendIncludeCycle(err, absPath)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// UpdateSSHHostStreaming is UpdateSSHHost for very large configs: the file is
+// copied line by line to a temporary file, rewriting only the block of oldName,
+// instead of being loaded into memory. The content is not normalized, even
+// with NormalizeOnWrite.
+func UpdateSSHHostStreaming(oldName string, newHost SSHHost) (err error) {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+	if newHost.Tags, err = normalizeTags(newHost.Tags); err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			err = recordOperation(OpUpdate, oldName, &newHost)
+		}
+	}()
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+
+	// The scan locating oldName also makes sure renaming doesn't create a
+	// duplicate host
+	names := []string{oldName}
+	if newHost.Name != oldName {
+		names = append(names, newHost.Name)
+	}
+	files, err := streamHostFiles(configPath, names...)
+	if err != nil {
+		return err
+	}
+	if _, exists := files[newHost.Name]; exists && newHost.Name != oldName {
+		return fmt.Errorf("host '%s' already exists", newHost.Name)
+	}
+	if path, ok := files[oldName]; ok {
+		configPath = path
+	}
+
+	// Create backup before modification
+	if err := backupConfig(configPath); err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+
+	return writeWithSidecarMetadata(func() error {
+		found, err := streamHostBlock(configPath, oldName, func(block []string) []string {
+			block, _ = replaceHostBlock(block, oldName, newHost)
+			return block
+		})
+		if err == nil && !found {
+			err = fmt.Errorf("host '%s' not found", oldName)
+		}
+		return err
+	}, []SSHHost{newHost}, oldName)
+}
+
+// DeleteSSHHostStreaming is DeleteSSHHost for very large configs, copying the
+// file line by line without the block of hostName as UpdateSSHHostStreaming
+// does
+func DeleteSSHHostStreaming(hostName string) (err error) {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+	defer func() {
+		if err == nil {
+			err = recordOperation(OpDelete, hostName, nil)
+		}
+	}()
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+	if configPath, err = streamHostFilePath(configPath, hostName); err != nil {
+		return err
+	}
+
+	// Create backup before modification
+	if err := backupConfig(configPath); err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+
+	return writeWithSidecarMetadata(func() error {
+		found, err := streamHostBlock(configPath, hostName, func(block []string) []string {
+			block, _ = removeHostBlock(block, hostName)
+			return block
+		})
+		if err == nil && !found {
+			err = fmt.Errorf("host '%s' not found", hostName)
+		}
+		return err
+	}, nil, hostName)
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// largeConfig returns a generated config with n hosts, each with a comment,
+// tags and a few directives
+func largeConfig(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "# Tags: gen%d\nHost host%05d\n    HostName 10.%d.%d.%d\n    User deploy\n    Port 22\n    IdentityFile ~/.ssh/id_ed25519\n\n",
+			i%10, i, i/65536, i/256%256, i%256)
+	}
+	return b.String()
+}
+
+func TestStreamingMatchesInMemory(t *testing.T) {
+	config := largeConfig(50)
+	update := SSHHost{Name: "renamed", Hostname: "10.1.1.1", User: "root", Port: "2222", Tags: []string{"gen5"}}
+
+	tests := []struct {
+		name      string
+		inMemory  func() error
+		streaming func() error
+	}{
+		{
+			name:      "update",
+			inMemory:  func() error { return UpdateSSHHost("host00025", update) },
+			streaming: func() error { return UpdateSSHHostStreaming("host00025", update) },
+		},
+		{
+			name:      "delete",
+			inMemory:  func() error { return DeleteSSHHost("host00025") },
+			streaming: func() error { return DeleteSSHHostStreaming("host00025") },
+		},
+		{
+			name:      "delete last",
+			inMemory:  func() error { return DeleteSSHHost("host00049") },
+			streaming: func() error { return DeleteSSHHostStreaming("host00049") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useTestConfig(t, config)
+			if err := tt.inMemory(); err != nil {
+				t.Fatal(err)
+			}
+			want := readTestFile(t, path)
+
+			path = useTestConfig(t, config)
+			if err := tt.streaming(); err != nil {
+				t.Fatal(err)
+			}
+			if got := readTestFile(t, path); got != want {
+				t.Errorf("streaming result differs from in-memory one:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+// benchmarkHosts is the size of the generated config of the benchmarks
+const benchmarkHosts = 20000
+
+// benchmarkWrite runs write on a fresh copy of a large generated config at
+// each iteration, resetting the file outside of the timer
+func benchmarkWrite(b *testing.B, write func() error) {
+	config := []byte(largeConfig(benchmarkHosts))
+	path := useTestConfig(b, "")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		if err := os.WriteFile(path, config, 0600); err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		if err := write(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+var benchmarkUpdate = SSHHost{Name: "host10000", Hostname: "10.1.1.1", User: "root", Port: "2222", Tags: []string{"gen0"}}
+
+func BenchmarkUpdateSSHHost(b *testing.B) {
+	benchmarkWrite(b, func() error { return UpdateSSHHost("host10000", benchmarkUpdate) })
+}
+
+func BenchmarkUpdateSSHHostStreaming(b *testing.B) {
+	benchmarkWrite(b, func() error { return UpdateSSHHostStreaming("host10000", benchmarkUpdate) })
+}
+
+func BenchmarkDeleteSSHHost(b *testing.B) {
+	benchmarkWrite(b, func() error { return DeleteSSHHost("host10000") })
+}
+
+func BenchmarkDeleteSSHHostStreaming(b *testing.B) {
+	benchmarkWrite(b, func() error { return DeleteSSHHostStreaming("host10000") })
+}
+
+func TestStreamingIncludedHost(t *testing.T) {
+	path, extraPath := useTestConfigWithInclude(t, "Host web\n    HostName web.example\n", "Host db\n    HostName db.example\n")
+	mainBefore := readTestFile(t, path)
+
+	if err := UpdateSSHHostStreaming("db", SSHHost{Name: "db", Hostname: "db2.example", Port: "22"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readTestFile(t, extraPath), "Host db\n    HostName db2.example\n"; got != want {
+		t.Errorf("included config = %q, want %q", got, want)
+	}
+
+	if err := DeleteSSHHostStreaming("db"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, extraPath); strings.Contains(got, "Host db") {
+		t.Errorf("included config still holds db: %q", got)
+	}
+	if got := readTestFile(t, path); got != mainBefore {
+		t.Errorf("main config = %q, want it unchanged", got)
+	}
+}
+
+func TestStreamingRenameCollision(t *testing.T) {
+	path, extraPath := useTestConfigWithInclude(t, "Host web\n    HostName web.example\n", "Host db\n    HostName db.example\n")
+	mainBefore, extraBefore := readTestFile(t, path), readTestFile(t, extraPath)
+
+	for _, rename := range [][2]string{{"web", "db"}, {"db", "web"}} {
+		err := UpdateSSHHostStreaming(rename[0], SSHHost{Name: rename[1], Hostname: "new.example"})
+		if err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Errorf("renaming %s to %s: err = %v, want the host to already exist", rename[0], rename[1], err)
+		}
+	}
+	if readTestFile(t, path) != mainBefore || readTestFile(t, extraPath) != extraBefore {
+		t.Error("a failed rename modified the config")
+	}
+
+	if err := UpdateSSHHostStreaming("db", SSHHost{Name: "cache", Hostname: "db.example"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readTestFile(t, extraPath), "Host cache\n    HostName db.example\n"; got != want {
+		t.Errorf("included config = %q, want %q", got, want)
+	}
+}
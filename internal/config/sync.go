@@ -0,0 +1,103 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// syncFileName is the file, next to the main config, that receives the hosts
+// synchronized by SyncFromSource
+const syncFileName = "gosshm_sync"
+
+// syncFileHeader is written at the top of a newly created sync file
+const syncFileHeader = "# Managed by gosshm: hosts in this file are synchronized automatically, do not edit"
+
+// syncOnce fetches the desired hosts and reconciles them into the sync file
+func syncOnce(configPath string, fetch func() ([]SSHHost, error)) error {
+	desired, err := fetch()
+	if err != nil {
+		return fmt.Errorf("failed to fetch hosts: %w", err)
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	syncPath := filepath.Join(filepath.Dir(configPath), syncFileName)
+
+	// Hosts the user defined elsewhere take precedence over synchronized ones
+	all, err := parseSSHConfigFile(configPath, make(map[string]bool))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	synced, err := parseSSHConfigFile(syncPath, nil)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	inSync := make(map[string]bool, len(synced))
+	for _, host := range synced {
+		inSync[host.Name] = true
+	}
+	userDefined := make(map[string]bool)
+	for _, host := range all {
+		if !inSync[host.Name] {
+			userDefined[host.Name] = true
+		}
+	}
+
+	var hosts []SSHHost
+	for _, host := range desired {
+		if !userDefined[host.Name] {
+			hosts = append(hosts, host)
+		}
+	}
+
+	if _, err := Files.Stat(syncPath); os.IsNotExist(err) {
+		if err := writeConfigFile(syncPath, []byte(syncFileHeader+"\n")); err != nil {
+			return err
+		}
+	}
+
+	if _, err := reconcileFile(syncPath, hosts, true); err != nil {
+		return err
+	}
+
+	return ensureInclude(configPath, syncPath)
+}
+
+// SyncFromSource keeps the config in sync with an external inventory. Every interval
+// it calls fetch and reconciles the returned hosts into a dedicated file included
+// from the main config, leaving user-defined hosts untouched. A failed sync is
+// passed to onError, when not nil, and retried at the next tick. It stops when
+// ctx is cancelled.
+func SyncFromSource(ctx context.Context, fetch func() ([]SSHHost, error), interval time.Duration, onError func(error)) error {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+	if interval <= 0 {
+		return fmt.Errorf("invalid sync interval %s: must be positive", interval)
+	}
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := syncOnce(configPath, fetch); err != nil && onError != nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncFromSourceInterval(t *testing.T) {
+	useTestConfig(t, "")
+	fetched := 0
+	fetch := func() ([]SSHHost, error) {
+		fetched++
+		return nil, nil
+	}
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if err := SyncFromSource(context.Background(), fetch, interval, nil); err == nil {
+			t.Errorf("SyncFromSource() with interval %s succeeded", interval)
+		}
+	}
+	if fetched != 0 {
+		t.Errorf("fetch called %d times with an invalid interval", fetched)
+	}
+}
+
+func TestSyncFromSourceKeepsRunning(t *testing.T) {
+	path := useTestConfig(t, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errFetch := errors.New("inventory unavailable")
+	fetched := 0
+	fetch := func() ([]SSHHost, error) {
+		fetched++
+		switch fetched {
+		case 1:
+			return nil, errFetch
+		case 3:
+			cancel()
+		}
+		return []SSHHost{{Name: "web", Hostname: "10.0.0.1"}}, nil
+	}
+	var reported []error
+	onError := func(err error) { reported = append(reported, err) }
+
+	if err := SyncFromSource(ctx, fetch, time.Millisecond, onError); !errors.Is(err, context.Canceled) {
+		t.Errorf("SyncFromSource() = %v, want %v", err, context.Canceled)
+	}
+	if fetched < 3 {
+		t.Errorf("fetch called %d times, want the sync retried after the failure", fetched)
+	}
+	if len(reported) != 1 || !errors.Is(reported[0], errFetch) {
+		t.Errorf("reported errors = %v, want the failed fetch only", reported)
+	}
+	if synced := readTestFile(t, filepath.Join(filepath.Dir(path), syncFileName)); !strings.Contains(synced, "Host web") {
+		t.Errorf("sync file = %q, want web synchronized after the failure", synced)
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// systemConfigPath is the path of the system-wide SSH config, read by ssh after
+// the user's config
+var systemConfigPath = "/etc/ssh/ssh_config"
+
+// firstDefiningEntry returns the first entry of hosts matching hostName, ignoring
+// catch-all "Host *" entries, which apply to every host without defining any
+func firstDefiningEntry(hosts []SSHHost, hostName string) (SSHHost, bool) {
+	for _, host := range hosts {
+		if strings.TrimSpace(host.Name) == "*" {
+			continue
+		}
+		if host.Name == hostName || matchesHost(host.Name, hostName) {
+			return host, true
+		}
+	}
+	return SSHHost{}, false
+}
+
+// IsSystemHost reports whether the entry that defines hostName comes from the
+// system config (or a file it includes) rather than from the user's config, in
+// which case it may not be editable and changes belong in a user override. The
+// file holding the entry is returned, empty when no entry matches.
+func IsSystemHost(hostName string) (bool, string, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return false, "", err
+	}
+	if host, ok := firstDefiningEntry(hosts, hostName); ok {
+		return false, host.SourceFile, nil
+	}
+
+	systemHosts, err := ParseSSHConfigFile(systemConfigPath)
+	if os.IsNotExist(err) {
+		return false, "", nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+	if host, ok := firstDefiningEntry(systemHosts, hostName); ok {
+		return true, host.SourceFile, nil
+	}
+	return false, "", nil
+}
@@ -0,0 +1,291 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// SortConsolidatedTags makes ConsolidateTags and ConsolidateAllTags sort tags
+// alphabetically instead of keeping them in the order they first appear
+var SortConsolidatedTags bool
+
+// LowercaseTags makes AddSSHHost and UpdateSSHHost write tags in lowercase, so
+// that "Prod" and "prod" end up as the same tag
+var LowercaseTags bool
+
+// normalizeTags trims tags, lowercases them if requested and drops empty and
+// repeated ones. Tags containing a comma, '#' or a line break are rejected, as
+// they can't be written to a "# Tags:" line and read back as the same tag.
+func normalizeTags(tags []string) ([]string, error) {
+	var result []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		if strings.ContainsAny(tag, ",#\r\n") {
+			return nil, fmt.Errorf("invalid tag '%s': tags can't contain commas, '#' or line breaks", strings.TrimSpace(tag))
+		}
+		tag = strings.TrimSpace(tag)
+		if LowercaseTags {
+			tag = strings.ToLower(tag)
+		}
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	return result, nil
+}
+
+// consolidatedTags returns tags without duplicates, sorted if requested
+func consolidatedTags(tags []string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		if !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	if SortConsolidatedTags {
+		sort.Strings(result)
+	}
+	return result
+}
+
+// consolidateHostTags rewrites the block of host with a single "# Tags:" line.
+// Tag lines separated from the Host line by empty lines or other comments are
+// still applied to it by the parser; they are removed as well. It reports
+// whether anything had to change.
+func consolidateHostTags(lines []string, host SSHHost) ([]string, bool) {
+	start, end, found := findHostBlock(lines, host.Name)
+	if !found {
+		return lines, false
+	}
+
+	tagLines := 0
+	for _, line := range lines[start:end] {
+		if strings.HasPrefix(strings.TrimSpace(line), "# Tags:") {
+			tagLines++
+		}
+	}
+	var stray []int
+	for i := start - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line != "" && !strings.HasPrefix(line, "#") {
+			break
+		}
+		if strings.HasPrefix(line, "# Tags:") {
+			stray = append(stray, i)
+		}
+	}
+
+	tags := consolidatedTags(host.Tags)
+	if len(stray) == 0 && tagLines <= 1 && slices.Equal(tags, host.Tags) {
+		return lines, false
+	}
+
+	// stray is in descending order, so removing a line doesn't shift the next
+	for _, i := range stray {
+		lines = append(lines[:i:i], lines[i+1:]...)
+		// Don't leave two empty lines where the tag line separated them
+		if i > 0 && i < len(lines) && strings.TrimSpace(lines[i-1]) == "" && strings.TrimSpace(lines[i]) == "" {
+			lines = append(lines[:i:i], lines[i+1:]...)
+		}
+	}
+	host.Tags = tags
+	lines, _ = replaceHostBlock(lines, host.Name, host)
+	return lines, true
+}
+
+// consolidateTags consolidates the tags of the hosts accepted by keep, in the
+// config or the included file defining them, with a single backed-up write of
+// each file changed, and returns the names of the hosts changed
+func consolidateTags(keep func(SSHHost) bool) ([]string, error) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := collectConfigFiles(configPath, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := parseSSHConfigFile(configPath, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	if err := applySidecarMetadata(hosts); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(files))
+	var changedFiles []string
+	contents := make(map[string][]string)
+	var changed []string
+	var written []SSHHost
+	for _, file := range files {
+		if seen[file] {
+			continue
+		}
+		seen[file] = true
+		content, err := readConfigFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		lines := strings.Split(string(content), "\n")
+		fileChanged := false
+		for _, host := range hosts {
+			if host.SourceFile != file || !keep(host) {
+				continue
+			}
+			var ok bool
+			if lines, ok = consolidateHostTags(lines, host); ok {
+				changed = append(changed, host.Name)
+				host.Tags = consolidatedTags(host.Tags)
+				written = append(written, host)
+				fileChanged = true
+			}
+		}
+		if fileChanged {
+			changedFiles = append(changedFiles, file)
+			contents[file] = lines
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	for _, file := range changedFiles {
+		// Create backup before modification
+		if err := backupConfig(file); err != nil {
+			return nil, fmt.Errorf("failed to create backup: %w", err)
+		}
+	}
+
+	if err := writeWithSidecarMetadata(func() error {
+		for _, file := range changedFiles {
+			if err := writeConfigFile(file, []byte(strings.Join(contents[file], "\n"))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, written); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// ConsolidateTags merges the tags of a host, possibly spread over several
+// "# Tags:" lines and repeated, into a single line without duplicates
+func ConsolidateTags(hostName string) error {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+
+	exists, err := HostExists(hostName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("host '%s' not found", hostName)
+	}
+
+	_, err = consolidateTags(func(host SSHHost) bool { return host.Name == hostName })
+	return err
+}
+
+// ConsolidateAllTags consolidates the tags of every host of the config and the
+// files it includes, as ConsolidateTags does, and returns the number of hosts
+// changed
+func ConsolidateAllTags() (int, error) {
+	if ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	changed, err := consolidateTags(func(SSHHost) bool { return true })
+	return len(changed), err
+}
+
+// DeleteHostsByTag deletes every host carrying tag from the config, the files
+// it includes and ManagedFile when set, with a single backup of each file
+// written, and returns their names. Each file is written at once; should a write fail, deleted
+// lists the hosts already removed from the files written before it.
+func DeleteHostsByTag(tag string) (deleted []string, err error) {
+	if ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	files, err := collectConfigFiles(configPath, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	if managedPath, err := managedFilePath(configPath); err != nil {
+		return nil, err
+	} else if managedPath != "" && !slices.Contains(files, managedPath) {
+		files = append(files, managedPath)
+	}
+
+	seen := make(map[string]bool, len(files))
+	for _, file := range files {
+		if seen[file] {
+			continue
+		}
+		seen[file] = true
+		content, err := readConfigFile(file)
+		if os.IsNotExist(err) && file != configPath {
+			continue
+		}
+		if err != nil {
+			return deleted, err
+		}
+		hosts, err := parseSSHConfigFile(file, nil)
+		if err != nil {
+			return deleted, err
+		}
+		if err := applySidecarMetadata(hosts); err != nil {
+			return deleted, err
+		}
+
+		lines := strings.Split(string(content), "\n")
+		var removed []string
+		for _, host := range FilterByTag(hosts, tag) {
+			var found bool
+			if lines, found = removeHostBlock(lines, host.Name); found {
+				removed = append(removed, host.Name)
+			}
+		}
+		if len(removed) == 0 {
+			continue
+		}
+
+		// Create backup before modification
+		if err := backupConfig(file); err != nil {
+			return deleted, fmt.Errorf("failed to create backup: %w", err)
+		}
+		if err := writeWithSidecarMetadata(func() error {
+			return writeConfigFile(file, []byte(strings.Join(lines, "\n")))
+		}, nil, removed...); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, removed...)
+		for _, name := range removed {
+			if err := recordOperation(OpDelete, name, nil); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const stagingConfig = `# Tags: staging-old, web
+Host web-old
+    HostName 10.0.1.1
+
+# Tags: production
+Host web
+    HostName 10.0.0.1
+
+# Tags: db, staging-old
+Host db-old
+    HostName 10.0.1.2
+`
+
+func TestDeleteHostsByTag(t *testing.T) {
+	tests := []struct {
+		name        string
+		tag         string
+		wantDeleted []string
+		want        string
+	}{
+		{
+			name:        "two of three",
+			tag:         "staging-old",
+			wantDeleted: []string{"web-old", "db-old"},
+			want:        "# Tags: production\nHost web\n    HostName 10.0.0.1\n",
+		},
+		{
+			name:        "one",
+			tag:         "production",
+			wantDeleted: []string{"web"},
+			want:        "# Tags: staging-old, web\nHost web-old\n    HostName 10.0.1.1\n\n# Tags: db, staging-old\nHost db-old\n    HostName 10.0.1.2\n",
+		},
+		{
+			name: "none",
+			tag:  "qa",
+			want: stagingConfig,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useTestConfig(t, stagingConfig)
+			deleted, err := DeleteHostsByTag(tt.tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(deleted, tt.wantDeleted) {
+				t.Errorf("DeleteHostsByTag() = %q, want %q", deleted, tt.wantDeleted)
+			}
+			if got := readTestFile(t, path); got != tt.want {
+				t.Errorf("config = %q, want %q", got, tt.want)
+			}
+
+			// A single backup is taken, of the config as it was
+			backups, err := listBackups(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantBackups := 1
+			if tt.wantDeleted == nil {
+				wantBackups = 0
+			}
+			if len(backups) != wantBackups {
+				t.Fatalf("backups = %q, want %d", backups, wantBackups)
+			}
+			if wantBackups == 1 && readTestFile(t, backups[0]) != stagingConfig {
+				t.Errorf("backup = %q, want the original config", readTestFile(t, backups[0]))
+			}
+		})
+	}
+}
+
+func TestDeleteHostsByTagManagedFile(t *testing.T) {
+	path := useTestConfig(t, "Include gosshm_hosts\n\n"+stagingConfig)
+	setForTest(t, &ManagedFile, "gosshm_hosts")
+	managedPath := filepath.Join(filepath.Dir(path), "gosshm_hosts")
+	if err := os.WriteFile(managedPath, []byte("# Tags: staging-old\nHost cache-old\n    HostName 10.0.1.3\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := DeleteHostsByTag("staging-old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"web-old", "db-old", "cache-old"}; !reflect.DeepEqual(deleted, want) {
+		t.Errorf("DeleteHostsByTag() = %q, want %q", deleted, want)
+	}
+	if got, want := readTestFile(t, managedPath), ""; got != want {
+		t.Errorf("managed file = %q, want %q", got, want)
+	}
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0].Name != "web" {
+		t.Errorf("hosts left = %+v, want web only", hosts)
+	}
+}
+
+func TestDeleteHostsByTagIncludedFile(t *testing.T) {
+	path, extraPath := useTestConfigWithInclude(t, stagingConfig,
+		"# Tags: staging-old\nHost cache-old\n    HostName 10.0.1.3\n\nHost cache\n    HostName 10.0.0.3\n")
+
+	deleted, err := DeleteHostsByTag("staging-old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"web-old", "db-old", "cache-old"}; !reflect.DeepEqual(deleted, want) {
+		t.Errorf("DeleteHostsByTag() = %q, want %q", deleted, want)
+	}
+	if got, want := readTestFile(t, extraPath), "Host cache\n    HostName 10.0.0.3\n"; got != want {
+		t.Errorf("included file = %q, want %q", got, want)
+	}
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := FilterByTag(hosts, "staging-old"); len(got) != 0 {
+		t.Errorf("hosts left with the tag = %+v, want none", got)
+	}
+	if !strings.HasPrefix(readTestFile(t, path), "Include extra.conf\n") {
+		t.Error("DeleteHostsByTag() dropped the Include of the config")
+	}
+}
+
+func TestDeleteHostsByTagSidecar(t *testing.T) {
+	path := useTestConfig(t, "Host web-old\n    HostName 10.0.1.1\n\nHost web\n    HostName 10.0.0.1\n")
+	setForTest(t, &MetadataMode, MetadataInSidecar)
+	if err := UpdateSSHHost("web-old", SSHHost{Name: "web-old", Hostname: "10.0.1.1", Tags: []string{"staging-old"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := DeleteHostsByTag("staging-old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"web-old"}; !reflect.DeepEqual(deleted, want) {
+		t.Errorf("DeleteHostsByTag() = %q, want %q", deleted, want)
+	}
+	if got, want := readTestFile(t, path), "Host web\n    HostName 10.0.0.1\n"; got != want {
+		t.Errorf("config = %q, want %q", got, want)
+	}
+}
+
+// failingRename is the FileSystem of the OS, except that files can't be
+// renamed to path, which makes atomic writes of path fail
+type failingRename struct {
+	OSFileSystem
+	path string
+}
+
+func (f failingRename) Rename(oldpath, newpath string) error {
+	if newpath == f.path {
+		return errors.New("rename failed")
+	}
+	return f.OSFileSystem.Rename(oldpath, newpath)
+}
+
+func TestDeleteHostsByTagPartialFailure(t *testing.T) {
+	path := useTestConfig(t, "Include gosshm_hosts\n\n"+stagingConfig)
+	setForTest(t, &ManagedFile, "gosshm_hosts")
+	managedPath := filepath.Join(filepath.Dir(path), "gosshm_hosts")
+	const managed = "# Tags: staging-old\nHost cache-old\n    HostName 10.0.1.3\n"
+	if err := os.WriteFile(managedPath, []byte(managed), 0600); err != nil {
+		t.Fatal(err)
+	}
+	setForTest[FileSystem](t, &Files, failingRename{path: managedPath})
+
+	deleted, err := DeleteHostsByTag("staging-old")
+	if err == nil {
+		t.Fatal("DeleteHostsByTag() succeeded, want the write of the managed file to fail")
+	}
+	if want := []string{"web-old", "db-old"}; !reflect.DeepEqual(deleted, want) {
+		t.Errorf("DeleteHostsByTag() = %q, want the hosts of the config %q", deleted, want)
+	}
+	if got := readTestFile(t, managedPath); got != managed {
+		t.Errorf("managed file = %q, want it unchanged", got)
+	}
+}
+
+func TestConsolidateTagsIncludedFile(t *testing.T) {
+	const mainConfig = "# Tags: web, web\nHost web\n    HostName 10.0.0.1\n"
+	path, extraPath := useTestConfigWithInclude(t, mainConfig,
+		"# Tags: db, primary, db\nHost db\n    HostName 10.0.0.2\n")
+
+	if err := ConsolidateTags("db"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readTestFile(t, extraPath), "# Tags: db, primary\nHost db\n    HostName 10.0.0.2\n"; got != want {
+		t.Errorf("included file = %q, want %q", got, want)
+	}
+	if got := readTestFile(t, path); got != "Include extra.conf\n\n"+mainConfig {
+		t.Errorf("config = %q, want it unchanged", got)
+	}
+
+	changed, err := ConsolidateAllTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 1 {
+		t.Errorf("ConsolidateAllTags() = %d, want web changed only", changed)
+	}
+	if web := hostNamed(t, mustParse(t, readTestFile(t, path)), "web"); !reflect.DeepEqual(web.Tags, []string{"web"}) {
+		t.Errorf("web tags = %q, want %q", web.Tags, []string{"web"})
+	}
+}
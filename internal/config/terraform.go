@@ -0,0 +1,195 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"sshm/internal/validation"
+)
+
+// terraformState is the part of a Terraform state file (format version 4) read
+// by ImportTerraformState
+type terraformState struct {
+	Version   int `json:"version"`
+	Resources []struct {
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Instances []struct {
+			IndexKey   any            `json:"index_key"`
+			Attributes map[string]any `json:"attributes"`
+		} `json:"instances"`
+	} `json:"resources"`
+}
+
+// terraformResourceType names the attributes of a resource type holding the
+// settings of a server
+type terraformResourceType struct {
+	Addresses []string // Public address attributes, in order of preference
+	User      string
+	Tags      string // Map of tags or labels, or list of tags
+}
+
+// terraformResourceTypes lists the server resources of common providers
+var terraformResourceTypes = map[string]terraformResourceType{
+	"aws_instance":                  {Addresses: []string{"public_ip", "public_dns"}, Tags: "tags"},
+	"aws_lightsail_instance":        {Addresses: []string{"public_ip_address"}, User: "username", Tags: "tags"},
+	"google_compute_instance":       {Tags: "labels"},
+	"azurerm_linux_virtual_machine": {Addresses: []string{"public_ip_address"}, User: "admin_username", Tags: "tags"},
+	"digitalocean_droplet":          {Addresses: []string{"ipv4_address"}, Tags: "tags"},
+	"hcloud_server":                 {Addresses: []string{"ipv4_address"}, Tags: "labels"},
+	"linode_instance":               {Addresses: []string{"ip_address"}, Tags: "tags"},
+	"vultr_instance":                {Addresses: []string{"main_ip"}, Tags: "tags"},
+	"openstack_compute_instance_v2": {Addresses: []string{"access_ip_v4"}, Tags: "tags"},
+	"scaleway_instance_server":      {Addresses: []string{"public_ip"}, Tags: "tags"},
+}
+
+// terraformString returns the string attribute key of attrs, if any
+func terraformString(attrs map[string]any, key string) string {
+	s, _ := attrs[key].(string)
+	return s
+}
+
+// gcpAddress returns the external address of a google_compute_instance, found
+// in the access configs of its network interfaces
+func gcpAddress(attrs map[string]any) string {
+	interfaces, _ := attrs["network_interface"].([]any)
+	for _, iface := range interfaces {
+		iface, _ := iface.(map[string]any)
+		configs, _ := iface["access_config"].([]any)
+		for _, config := range configs {
+			config, _ := config.(map[string]any)
+			if ip := terraformString(config, "nat_ip"); ip != "" {
+				return ip
+			}
+		}
+	}
+	return ""
+}
+
+// gcpUser returns the user of the first key of the ssh-keys metadata of a
+// google_compute_instance ("user:ssh-ed25519 AAAA...")
+func gcpUser(attrs map[string]any) string {
+	metadata, _ := attrs["metadata"].(map[string]any)
+	keys := terraformString(metadata, "ssh-keys")
+	if user, _, found := strings.Cut(strings.TrimSpace(keys), ":"); found {
+		return user
+	}
+	return ""
+}
+
+// terraformTags returns the tags of a resource: the values of a tags or labels
+// map, except the one naming the server, or the items of a tags list. Values
+// that can't be written as gosshm tags are skipped.
+func terraformTags(value any) []string {
+	var tags []string
+	add := func(tag any) {
+		if s, ok := tag.(string); ok && s != "" && !strings.ContainsAny(s, ",\n") {
+			tags = append(tags, s)
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			if !strings.EqualFold(key, "name") {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			add(value[key])
+		}
+	case []any:
+		for _, tag := range value {
+			add(tag)
+		}
+	}
+	return tags
+}
+
+// terraformHostName picks the name of a server: its Name tag or label, else its
+// name attribute, else the resource name with the instance key
+func terraformHostName(resourceName string, indexKey any, attrs map[string]any, tagsKey string) string {
+	var candidates []string
+	if tags, ok := attrs[tagsKey].(map[string]any); ok {
+		for key, value := range tags {
+			if s, ok := value.(string); ok && strings.EqualFold(key, "name") {
+				candidates = append(candidates, s)
+			}
+		}
+	}
+	candidates = append(candidates, terraformString(attrs, "name"), terraformString(attrs, "label"))
+	for _, name := range candidates {
+		if name != "" && validation.ValidateHostName(name) && !IsPattern(name) {
+			return name
+		}
+	}
+
+	if indexKey != nil {
+		return fmt.Sprintf("%s-%v", resourceName, indexKey)
+	}
+	return resourceName
+}
+
+// ImportTerraformState reads a Terraform state file and returns a host for each
+// server of a known provider that has a public address, named after its Name
+// tag or name, with the values of its tags or labels as tags. Names used more
+// than once get a numbered suffix. The hosts are not added to the config.
+func ImportTerraformState(path string) ([]SSHHost, error) {
+	content, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var state terraformState
+	if err := json.Unmarshal(content, &state); err != nil {
+		return nil, fmt.Errorf("invalid Terraform state: %w", err)
+	}
+	if state.Version != 4 {
+		return nil, fmt.Errorf("unsupported Terraform state version %d", state.Version)
+	}
+
+	var hosts []SSHHost
+	names := make(map[string]int)
+	for _, resource := range state.Resources {
+		kind, ok := terraformResourceTypes[resource.Type]
+		if !ok || resource.Mode != "managed" {
+			continue
+		}
+		for _, instance := range resource.Instances {
+			attrs := instance.Attributes
+
+			var address, user string
+			for _, key := range kind.Addresses {
+				if address = terraformString(attrs, key); address != "" {
+					break
+				}
+			}
+			if kind.User != "" {
+				user = terraformString(attrs, kind.User)
+			}
+			if resource.Type == "google_compute_instance" {
+				address, user = gcpAddress(attrs), gcpUser(attrs)
+			}
+			if address == "" {
+				continue
+			}
+
+			name := terraformHostName(resource.Name, instance.IndexKey, attrs, kind.Tags)
+			if names[name]++; names[name] > 1 {
+				name = fmt.Sprintf("%s-%d", name, names[name])
+			}
+			hosts = append(hosts, SSHHost{
+				Name:     name,
+				Hostname: address,
+				User:     user,
+				Tags:     terraformTags(attrs[kind.Tags]),
+			})
+		}
+	}
+	return hosts, nil
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultIndent is the indentation gosshm writes before directives
+const defaultIndent = "    "
+
+// leadingIndent returns the whitespace at the start of line
+func leadingIndent(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// tidyLines re-indents the Host and Match blocks of lines: block lines are put
+// flush-left and their directives indented like the first indented directive
+// of the block (four spaces if none is). It returns the number of lines changed.
+func tidyLines(lines []string) ([]string, int) {
+	tidied := append([]string{}, lines...)
+	changed := 0
+
+	for i := 0; i < len(tidied); i++ {
+		keyword := lineKeyword(tidied[i])
+		if keyword != "host" && keyword != "match" {
+			continue
+		}
+		end := hostBlockEnd(tidied, i)
+
+		if trimmed := strings.TrimLeft(tidied[i], " \t"); trimmed != tidied[i] {
+			tidied[i] = trimmed
+			changed++
+		}
+
+		indent := defaultIndent
+		for _, line := range tidied[i+1 : end] {
+			if line := leadingIndent(line); line != "" {
+				indent = line
+				break
+			}
+		}
+
+		for j := i + 1; j < end; j++ {
+			trimmed := strings.TrimSpace(tidied[j])
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			if tidied[j] != indent+trimmed {
+				tidied[j] = indent + trimmed
+				changed++
+			}
+		}
+		i = end - 1
+	}
+	return tidied, changed
+}
+
+// TidyConfig re-indents the blocks of the config, such as directives written
+// flush-left under a Host line, and returns the number of lines fixed
+func TidyConfig() (int, error) {
+	if ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return 0, err
+	}
+
+	content, err := readConfigFile(configPath)
+	if err != nil {
+		return 0, err
+	}
+
+	lines, changed := tidyLines(strings.Split(string(content), "\n"))
+	if changed == 0 {
+		return 0, nil
+	}
+
+	// Create backup before modification
+	if err := backupConfig(configPath); err != nil {
+		return 0, fmt.Errorf("failed to create backup: %w", err)
+	}
+
+	return changed, writeConfigFile(configPath, []byte(strings.Join(lines, "\n")))
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+const flushLeftConfig = `Host web
+HostName web.example
+User deploy
+
+Port 2222
+Host db
+	HostName db.example
+  User postgres
+`
+
+func TestParseFlushLeftDirectives(t *testing.T) {
+	hosts := mustParse(t, flushLeftConfig)
+
+	tests := []struct {
+		host, hostname, user, port string
+	}{
+		{"web", "web.example", "deploy", "2222"},
+		{"db", "db.example", "postgres", "22"},
+	}
+	for _, tt := range tests {
+		host := hostNamed(t, hosts, tt.host)
+		if host.Hostname != tt.hostname || host.User != tt.user || host.Port != tt.port {
+			t.Errorf("%s = %s@%s:%s, want %s@%s:%s", tt.host,
+				host.User, host.Hostname, host.Port, tt.user, tt.hostname, tt.port)
+		}
+	}
+}
+
+func TestTidyLines(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		want        string
+		wantChanged int
+	}{
+		{
+			name:        "flush-left",
+			input:       "Host web\nHostName web.example\nUser deploy",
+			want:        "Host web\n    HostName web.example\n    User deploy",
+			wantChanged: 2,
+		},
+		{
+			name:        "follows first indent",
+			input:       "  Host db\n\tHostName db.example\n  User postgres\n\n# note\nPort 5432",
+			want:        "Host db\n\tHostName db.example\n\tUser postgres\n\n# note\n\tPort 5432",
+			wantChanged: 3,
+		},
+		{
+			name:        "tidy",
+			input:       "Host web\n    HostName web.example",
+			want:        "Host web\n    HostName web.example",
+			wantChanged: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, changed := tidyLines(strings.Split(tt.input, "\n"))
+			if got := strings.Join(lines, "\n"); got != tt.want {
+				t.Errorf("tidyLines() = %q, want %q", got, tt.want)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("tidyLines() changed %d lines, want %d", changed, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestTidyConfig(t *testing.T) {
+	path := useTestConfig(t, flushLeftConfig)
+
+	changed, err := TidyConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 4 {
+		t.Errorf("TidyConfig() fixed %d lines, want 4", changed)
+	}
+
+	want := "Host web\n    HostName web.example\n    User deploy\n\n    Port 2222\nHost db\n\tHostName db.example\n\tUser postgres\n"
+	if got := readTestFile(t, path); got != want {
+		t.Errorf("config = %q, want %q", got, want)
+	}
+	if hosts := mustParse(t, want); hostNamed(t, hosts, "web").Port != "2222" {
+		t.Error("tidied config no longer sets the port of web")
+	}
+}
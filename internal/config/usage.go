@@ -0,0 +1,189 @@
+package config
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// HostUsage summarizes how often a host is connected to
+type HostUsage struct {
+	Name             string
+	TotalConnections int
+	LastConnected    time.Time
+	AvgPerWeek       float64
+}
+
+// UsageSortKey selects the field ConnectionStats results are sorted by
+type UsageSortKey int
+
+const (
+	UsageByConnections UsageSortKey = iota
+	UsageByLastConnected
+	UsageByAvgPerWeek
+)
+
+const week = 7 * 24 * time.Hour
+
+// ConnectionStats returns the usage of every host of the config, most used
+// first. Hosts that were never connected to are included with zero counts.
+func ConnectionStats() ([]HostUsage, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	sidecarMutex.Lock()
+	data, err := loadSidecar()
+	sidecarMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var stats []HostUsage
+	for _, host := range hosts {
+		if IsPattern(host.Name) {
+			continue
+		}
+
+		usage := HostUsage{Name: host.Name}
+		if record, ok := data.Usage[host.Name]; ok {
+			usage.TotalConnections = record.Connections
+			usage.LastConnected = record.LastConnected
+
+			// Average over the time since the first connection, counting at least a week
+			weeks := float64(now.Sub(record.FirstConnected)) / float64(week)
+			if weeks < 1 {
+				weeks = 1
+			}
+			usage.AvgPerWeek = float64(record.Connections) / weeks
+		}
+		stats = append(stats, usage)
+	}
+
+	SortUsage(stats, UsageByConnections)
+	return stats, nil
+}
+
+// SortUsage sorts usage stats in descending order of the given key, breaking
+// ties by host name
+func SortUsage(stats []HostUsage, by UsageSortKey) {
+	sort.SliceStable(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		switch by {
+		case UsageByLastConnected:
+			if !a.LastConnected.Equal(b.LastConnected) {
+				return a.LastConnected.After(b.LastConnected)
+			}
+		case UsageByAvgPerWeek:
+			if a.AvgPerWeek != b.AvgPerWeek {
+				return a.AvgPerWeek > b.AvgPerWeek
+			}
+		default:
+			if a.TotalConnections != b.TotalConnections {
+				return a.TotalConnections > b.TotalConnections
+			}
+		}
+		return a.Name < b.Name
+	})
+}
+
+// GetNeverConnectedHosts returns the concrete hosts without any connection in
+// the usage data, as cleanup candidates. The hosts already present in the
+// oldest backups come first, since they have been left unused the longest;
+// hosts newer than every backup come last. Ties are broken by host name.
+func GetNeverConnectedHosts() ([]SSHHost, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	sidecarMutex.Lock()
+	data, err := loadSidecar()
+	sidecarMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var never []SSHHost
+	for _, host := range hosts {
+		if _, used := data.Usage[host.Name]; !used && !IsPattern(host.Name) {
+			never = append(never, host)
+		}
+	}
+	if len(never) == 0 {
+		return nil, nil
+	}
+
+	since, err := oldestBackupTimes()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(never, func(i, j int) bool {
+		a, b := since[never[i].Name], since[never[j].Name]
+		switch {
+		case a.IsZero() != b.IsZero():
+			return !a.IsZero()
+		case !a.Equal(b):
+			return a.Before(b)
+		}
+		return never[i].Name < never[j].Name
+	})
+	return never, nil
+}
+
+// connectIntentDirectives holds the lowercased keywords showing a host is meant
+// to be connected to for itself, not only jumped through
+var connectIntentDirectives = map[string]bool{
+	"remotecommand": true,
+	"requesttty":    true,
+}
+
+// hasConnectIntent reports whether host sets forwards or a remote command
+func hasConnectIntent(host SSHHost) bool {
+	if len(host.DynamicForwards) > 0 || len(host.LocalForwards) > 0 || len(host.RemoteForwards) > 0 {
+		return true
+	}
+	for key := range host.Extra {
+		if connectIntentDirectives[strings.ToLower(key)] {
+			return true
+		}
+	}
+	return false
+}
+
+// GetJumpOnlyHosts returns the concrete hosts that other hosts use as a
+// ProxyJump hop and that look like they are only used that way: they set no
+// forwards or remote command, and the usage data has no direct connection to
+// them. Hosts are in config order.
+func GetJumpOnlyHosts() ([]SSHHost, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	sidecarMutex.Lock()
+	data, err := loadSidecar()
+	sidecarMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	jumpTargets := make(map[string]bool)
+	for _, host := range hosts {
+		if IsPattern(host.Name) {
+			continue
+		}
+		for _, hop := range jumpHosts(resolveHost(hosts, host.Name).ProxyJump) {
+			if hop != host.Name {
+				jumpTargets[hop] = true
+			}
+		}
+	}
+
+	return FilterHosts(hosts, func(host SSHHost) bool {
+		_, used := data.Usage[host.Name]
+		return jumpTargets[host.Name] && !IsPattern(host.Name) && !used && !hasConnectIntent(host)
+	}), nil
+}
@@ -0,0 +1,342 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+
+	"sshm/internal/validation"
+)
+
+// Severity tells how serious a config issue is
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+// String returns the name of the severity
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// ConfigIssue is a problem found while validating the config
+type ConfigIssue struct {
+	Severity Severity
+	Host     string
+	File     string
+	Line     int
+	Message  string
+}
+
+// BrokenJump is a host whose ProxyJump refers to a host that is not defined
+type BrokenJump struct {
+	Host   string
+	Target string
+}
+
+// findBrokenProxyJumps checks the ProxyJump hops of every host. Hops that look
+// like real host names (containing a dot, or IP addresses) are reachable
+// without a Host block, so only bare aliases are reported.
+func findBrokenProxyJumps(hosts []SSHHost) []BrokenJump {
+	var broken []BrokenJump
+	for _, host := range hosts {
+		for _, target := range jumpHosts(host.ProxyJump) {
+			if strings.Contains(target, ".") || net.ParseIP(target) != nil || target == "localhost" {
+				continue
+			}
+			if !isDefinedHost(hosts, target) {
+				broken = append(broken, BrokenJump{Host: host.Name, Target: target})
+			}
+		}
+	}
+	return broken
+}
+
+// FindBrokenProxyJumps lists the hosts whose ProxyJump refers to a host alias
+// that no Host block (wildcards included) defines
+func FindBrokenProxyJumps() ([]BrokenJump, error) {
+	hosts, err := ParseSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+	return findBrokenProxyJumps(hosts), nil
+}
+
+// maxFileDirectives is the number of IdentityFile, and of CertificateFile,
+// directives past which ssh refuses the config
+const maxFileDirectives = 100
+
+// algorithmListDirectives holds the lowercased keywords taking a single comma
+// separated list of algorithms
+var algorithmListDirectives = map[string]bool{
+	"ciphers":                     true,
+	"macs":                        true,
+	"kexalgorithms":               true,
+	"hostkeyalgorithms":           true,
+	"pubkeyacceptedalgorithms":    true,
+	"pubkeyacceptedkeytypes":      true,
+	"casignaturealgorithms":       true,
+	"hostbasedacceptedalgorithms": true,
+	"hostbasedkeytypes":           true,
+}
+
+// countDirectives holds the lowercased keywords taking a plain number, with
+// the smallest value ssh accepts
+var countDirectives = map[string]int{
+	"connectionattempts":      1,
+	"serveralivecountmax":     0,
+	"numberofpasswordprompts": 0,
+	"canonicalizemaxdots":     0,
+}
+
+// ignoredUnknown reports whether keyword matches one of the IgnoreUnknown
+// pattern lists, which ssh matches case-insensitively
+func ignoredUnknown(patterns []string, keyword string) bool {
+	keyword = strings.ToLower(keyword)
+	for _, list := range patterns {
+		for _, pattern := range strings.Split(strings.ToLower(list), ",") {
+			if ok, _ := path.Match(pattern, keyword); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkDirectives scans the lines of a config file for unknown keywords,
+// single-valued directives set more than once in a block, where ssh silently
+// uses the first value, and values ssh rejects. ignoreUnknown accumulates the
+// IgnoreUnknown patterns, which apply to the lines after them.
+func checkDirectives(file string, content []byte, ignoreUnknown *[]string) []ConfigIssue {
+	var issues []ConfigIssue
+	currentHost := ""
+	seen := make(map[string]int)
+	counts := make(map[string]int)
+
+	for i, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		keyword, value := splitDirective(trimmed)
+		key := strings.ToLower(keyword)
+		lineNum := i + 1
+		issue := func(severity Severity, format string, args ...any) {
+			issues = append(issues, ConfigIssue{
+				Severity: severity,
+				Host:     currentHost,
+				File:     file,
+				Line:     lineNum,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+
+		if key == "host" || key == "match" {
+			currentHost = strings.Join(configArgs(value), " ")
+			seen = make(map[string]int)
+			counts = make(map[string]int)
+			continue
+		}
+
+		if !isKnownKeyword(keyword) {
+			if ignoredUnknown(*ignoreUnknown, keyword) {
+				continue
+			}
+			if suggestion := suggestKeyword(keyword); suggestion != "" {
+				issue(SeverityWarning, "unknown keyword '%s', did you mean '%s'?", keyword, suggestion)
+			} else {
+				issue(SeverityWarning, "unknown keyword '%s'", keyword)
+			}
+			continue
+		}
+		if key == "ignoreunknown" {
+			*ignoreUnknown = append(*ignoreUnknown, value)
+		}
+
+		if repeatableDirectives[key] || key == "include" || key == "setenv" {
+			counts[key]++
+			if (key == "identityfile" || key == "certificatefile") && counts[key] == maxFileDirectives+1 {
+				issue(SeverityError, "more than %d %s directives, ssh refuses the config", maxFileDirectives, keyword)
+			}
+		} else if first, ok := seen[key]; ok {
+			issue(SeverityWarning, "%s is already set on line %d, ssh uses the first value", keyword, first)
+		} else {
+			seen[key] = lineNum
+		}
+
+		args := configArgs(value)
+		switch {
+		case key == "port":
+			if value == "" || !validation.ValidatePort(value) {
+				issue(SeverityError, "invalid Port '%s': expected a number between 1 and 65535", value)
+			}
+		case algorithmListDirectives[key]:
+			if len(args) != 1 {
+				issue(SeverityError, "invalid %s: expected a single comma separated list, without spaces", keyword)
+			} else if strings.Contains(","+strings.TrimLeft(args[0], "+-^")+",", ",,") {
+				issue(SeverityError, "invalid %s '%s': empty algorithm name", keyword, args[0])
+			}
+		default:
+			if least, ok := countDirectives[key]; ok {
+				if n, err := strconv.Atoi(value); err != nil || n < least {
+					issue(SeverityError, "invalid %s '%s': expected a number of at least %d", keyword, value, least)
+				}
+			}
+		}
+	}
+	return issues
+}
+
+// ValidateConfigFile checks a config file, and the files it includes, for
+// problems that would make ssh fail or behave unexpectedly. Issues are located
+// by file and line.
+func ValidateConfigFile(configPath string) ([]ConfigIssue, error) {
+	hosts, err := ParseSSHConfigFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	// The sidecar holds the metadata of the config gosshm manages only
+	if activePath, err := getConfigPath(); err == nil && activePath == configPath {
+		if err := applySidecarMetadata(hosts); err != nil {
+			return nil, err
+		}
+	}
+
+	// hostIssue reports an error at the Host line of host
+	var issues []ConfigIssue
+	hostIssue := func(host SSHHost, message string) {
+		issues = append(issues, ConfigIssue{
+			Severity: SeverityError,
+			Host:     host.Name,
+			File:     host.SourceFile,
+			Line:     host.LineNumber,
+			Message:  message,
+		})
+	}
+
+	for _, jump := range findBrokenProxyJumps(hosts) {
+		for _, host := range hosts {
+			if host.Name == jump.Host {
+				hostIssue(host, fmt.Sprintf("ProxyJump refers to undefined host '%s'", jump.Target))
+				break
+			}
+		}
+	}
+	for _, host := range hosts {
+		if host.AddressFamily != "" && !validation.ValidateAddressFamily(host.AddressFamily) {
+			hostIssue(host, fmt.Sprintf("invalid AddressFamily '%s': expected any, inet or inet6", host.AddressFamily))
+		}
+		for _, x11 := range []directive{
+			{"ForwardX11", host.ForwardX11},
+			{"ForwardX11Trusted", host.ForwardX11Trusted},
+		} {
+			if x11.Value != "" && !validation.ValidateYesNo(x11.Value) {
+				hostIssue(host, fmt.Sprintf("invalid %s '%s': expected yes or no", x11.Key, x11.Value))
+			}
+		}
+		for _, forward := range host.DynamicForwards {
+			if !validation.ValidateDynamicForward(forward) {
+				hostIssue(host, fmt.Sprintf("invalid DynamicForward '%s': expected [bind_address:]port", forward))
+			}
+		}
+	}
+
+	files, err := collectConfigFiles(configPath, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	var ignoreUnknown []string
+	for _, file := range files {
+		content, err := readConfigFile(file)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, checkDirectives(file, content, &ignoreUnknown)...)
+	}
+	return issues, nil
+}
+
+// ValidationWarning is a problem with a host that doesn't stop the config from
+// loading but likely isn't intended
+type ValidationWarning struct {
+	Host    string
+	Message string
+}
+
+// Validate checks parsed hosts for names defined more than once, for which ssh
+// uses the first value of each directive over all the blocks, concrete hosts
+// without a HostName, which ssh connects to by their alias, and invalid ports
+func Validate(hosts []SSHHost) []ValidationWarning {
+	var warnings []ValidationWarning
+	first := make(map[string]SSHHost)
+	for _, host := range hosts {
+		if prev, ok := first[host.Name]; ok {
+			warnings = append(warnings, ValidationWarning{
+				Host:    host.Name,
+				Message: fmt.Sprintf("defined more than once, first at %s:%d", prev.SourceFile, prev.LineNumber),
+			})
+		} else {
+			first[host.Name] = host
+		}
+
+		if host.Port != "" && !validation.ValidatePort(host.Port) {
+			warnings = append(warnings, ValidationWarning{
+				Host:    host.Name,
+				Message: fmt.Sprintf("invalid port '%s'", host.Port),
+			})
+		}
+	}
+
+	checked := make(map[string]bool)
+	for _, host := range hosts {
+		if IsPattern(host.Name) || checked[host.Name] {
+			continue
+		}
+		checked[host.Name] = true
+		hasHostname := false
+		for _, entry := range hosts {
+			if entry.Hostname != "" && (entry.Name == host.Name || matchesHost(entry.Name, host.Name)) {
+				hasHostname = true
+				break
+			}
+		}
+		if !hasHostname {
+			warnings = append(warnings, ValidationWarning{
+				Host:    host.Name,
+				Message: "no HostName, ssh connects to the alias itself",
+			})
+		}
+	}
+	return warnings
+}
+
+// ValidateSummary validates the config with ValidateConfigFile and condenses the
+// result for a CI gate: ok is false when there is any error, warnings alone
+// don't fail it
+func ValidateSummary() (ok bool, errorCount int, warningCount int, err error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return false, 0, 0, err
+	}
+
+	issues, err := ValidateConfigFile(configPath)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	for _, issue := range issues {
+		switch issue.Severity {
+		case SeverityError:
+			errorCount++
+		case SeverityWarning:
+			warningCount++
+		}
+	}
+	return errorCount == 0, errorCount, warningCount, nil
+}
@@ -32,6 +32,7 @@ type editFormModel struct {
 	err          string
 	success      bool
 	originalName string
+	originalHost config.SSHHost
 }
 
 func RunEditForm(hostName string) error {
@@ -99,6 +100,7 @@ func RunEditForm(hostName string) error {
 		inputs:       inputs,
 		focused:      nameInput,
 		originalName: hostName,
+		originalHost: *host,
 	}
 
 	// Open in separate window like add form
@@ -252,16 +254,16 @@ func (m *editFormModel) submitEditForm() tea.Cmd {
 			}
 		}
 
-		// Create updated host configuration
-		host := config.SSHHost{
-			Name:      name,
-			Hostname:  hostname,
-			User:      user,
-			Port:      port,
-			Identity:  identity,
-			ProxyJump: proxyJump,
-			Tags:      tags,
-		}
+		// Create updated host configuration, keeping the settings the form
+		// does not expose
+		host := m.originalHost
+		host.Name = name
+		host.Hostname = hostname
+		host.User = user
+		host.Port = port
+		host.Identity = identity
+		host.ProxyJump = proxyJump
+		host.Tags = tags
 
 		// Update the configuration
 		err := config.UpdateSSHHost(m.originalName, host)
@@ -2,8 +2,6 @@ package ui
 
 import (
 	"fmt"
-	"os/exec"
-	"sort"
 	"strings"
 
 	"sshm/internal/config"
@@ -18,6 +16,12 @@ var baseStyle = lipgloss.NewStyle().
 	BorderStyle(lipgloss.NormalBorder()).
 	BorderForeground(lipgloss.Color("240"))
 
+var detailStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("245"))
+
+var warningStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("214"))
+
 var searchStyle = lipgloss.NewStyle().
 	BorderStyle(lipgloss.RoundedBorder()).
 	BorderForeground(lipgloss.Color("36")).
@@ -28,6 +32,7 @@ type Model struct {
 	searchInput   textinput.Model
 	hosts         []config.SSHHost
 	filteredHosts []config.SSHHost
+	warnings      []config.ValidationWarning
 	searchMode    bool
 	deleteMode    bool
 	deleteHost    string
@@ -102,6 +107,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				m.hosts = sortHostsByName(hosts)
 				m.filteredHosts = m.hosts
+				m.warnings = config.Validate(hosts)
 				m.updateTableRows()
 				m.deleteMode = false
 				m.deleteHost = ""
@@ -112,7 +118,16 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				selected := m.table.SelectedRow()
 				if len(selected) > 0 {
 					hostName := selected[0] // Host name is in the first column
-					return m, tea.ExecProcess(exec.Command("ssh", hostName), func(err error) tea.Msg {
+					host := config.SSHHost{Name: hostName}
+					for _, h := range m.hosts {
+						if h.Name == hostName {
+							host = h
+							break
+						}
+					}
+					// Usage tracking is best effort and must never prevent a connection
+					_ = config.RecordConnection(hostName)
+					return m, tea.ExecProcess(config.ConnectCommand(host), func(err error) tea.Msg {
 						return tea.Quit()
 					})
 				}
@@ -183,6 +198,21 @@ func (m Model) View() string {
 	// Add table
 	view.WriteString(baseStyle.Render(m.table.View()))
 
+	// Show what the selected host is reached through, if not directly
+	if details := m.renderProxyDetails(); details != "" {
+		view.WriteString("\n" + detailStyle.Render(details))
+	}
+
+	// Show the forwards and connection settings of the selected host
+	if details := m.renderOptionDetails(); details != "" {
+		view.WriteString("\n" + detailStyle.Render(details))
+	}
+
+	// Warn about problems with the selected host
+	if warnings := m.renderHostWarnings(); warnings != "" {
+		view.WriteString("\n" + warningStyle.Render(warnings))
+	}
+
 	// Add help text
 	if !m.searchMode {
 		view.WriteString("\nUse ↑/↓ to navigate • Enter to connect • (a)dd • (e)dit • (d)elete • / to search • (q)uit")
@@ -193,14 +223,85 @@ func (m Model) View() string {
 	return view.String()
 }
 
+// renderProxyDetails describes the ProxyJump and ProxyCommand of the selected
+// host, which explain why a connection does not go to it directly
+func (m Model) renderProxyDetails() string {
+	selected := m.table.SelectedRow()
+	if len(selected) == 0 {
+		return ""
+	}
+
+	var details []string
+	for _, host := range m.hosts {
+		if host.Name != selected[0] {
+			continue
+		}
+		if host.ProxyJump != "" {
+			details = append(details, "ProxyJump: "+host.ProxyJump)
+		}
+		if host.ProxyCommand != "" {
+			details = append(details, "ProxyCommand: "+host.ProxyCommand)
+		}
+		break
+	}
+	return strings.Join(details, "\n")
+}
+
+// renderOptionDetails lists the forwards and connection settings of the
+// selected host
+func (m Model) renderOptionDetails() string {
+	selected := m.table.SelectedRow()
+	if len(selected) == 0 {
+		return ""
+	}
+
+	var details []string
+	add := func(key, value string) {
+		if value != "" {
+			details = append(details, key+": "+value)
+		}
+	}
+	for _, host := range m.hosts {
+		if host.Name != selected[0] {
+			continue
+		}
+		add("ForwardAgent", host.ForwardAgent)
+		for _, forward := range host.LocalForwards {
+			add("LocalForward", forward)
+		}
+		for _, forward := range host.RemoteForwards {
+			add("RemoteForward", forward)
+		}
+		add("ServerAliveInterval", host.ServerAliveInterval)
+		add("StrictHostKeyChecking", host.StrictHostKeyChecking)
+		add("ConnectTimeout", host.ConnectTimeout)
+		break
+	}
+	return strings.Join(details, "\n")
+}
+
+// renderHostWarnings lists the validation warnings of the selected host
+func (m Model) renderHostWarnings() string {
+	selected := m.table.SelectedRow()
+	if len(selected) == 0 {
+		return ""
+	}
+
+	var lines []string
+	for _, warning := range m.warnings {
+		if warning.Host == selected[0] {
+			lines = append(lines, "⚠ "+warning.Message)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 // sortHostsByName sorts a slice of SSH hosts alphabetically by name
 func sortHostsByName(hosts []config.SSHHost) []config.SSHHost {
 	sorted := make([]config.SSHHost, len(hosts))
 	copy(sorted, hosts)
 
-	sort.Slice(sorted, func(i, j int) bool {
-		return strings.ToLower(sorted[i].Name) < strings.ToLower(sorted[j].Name)
-	})
+	config.SortHosts(sorted, config.SortByName)
 
 	return sorted
 }
@@ -335,6 +436,7 @@ func NewModel(hosts []config.SSHHost) Model {
 		searchInput:   ti,
 		hosts:         sortedHosts,
 		filteredHosts: sortedHosts,
+		warnings:      config.Validate(hosts),
 		searchMode:    false,
 	}
 }
@@ -403,38 +505,14 @@ func RunInteractiveMode(hosts []config.SSHHost) error {
 	return nil
 }
 
-// filterHosts filters hosts based on search query (name or tags)
+// filterHosts filters hosts based on search query (name, hostname, user or tags)
 func (m Model) filterHosts(query string) []config.SSHHost {
 	if query == "" {
 		return sortHostsByName(m.hosts)
 	}
 
-	query = strings.ToLower(query)
-	var filtered []config.SSHHost
-
-	for _, host := range m.hosts {
-		// Check host name
-		if strings.Contains(strings.ToLower(host.Name), query) {
-			filtered = append(filtered, host)
-			continue
-		}
-
-		// Check hostname
-		if strings.Contains(strings.ToLower(host.Hostname), query) {
-			filtered = append(filtered, host)
-			continue
-		}
-
-		// Check tags
-		for _, tag := range host.Tags {
-			if strings.Contains(strings.ToLower(tag), query) {
-				filtered = append(filtered, host)
-				break
-			}
-		}
-	}
-
-	return sortHostsByName(filtered)
+	// Best matches first
+	return config.SearchHosts(m.hosts, query)
 }
 
 // updateTableRows updates the table with filtered hosts
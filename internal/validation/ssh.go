@@ -40,6 +40,42 @@ func ValidatePort(port string) bool {
 	return err == nil && portNum >= 1 && portNum <= 65535
 }
 
+// ValidateDynamicForward checks a DynamicForward spec of the form [bind_address:]port,
+// where an IPv6 bind address is enclosed in brackets
+func ValidateDynamicForward(spec string) bool {
+	port := spec
+	if i := strings.LastIndex(spec, ":"); i >= 0 {
+		bind := spec[:i]
+		port = spec[i+1:]
+		if strings.HasPrefix(bind, "[") {
+			if !strings.HasSuffix(bind, "]") || !ValidateIP(bind[1:len(bind)-1]) {
+				return false
+			}
+		} else if bind != "*" && bind != "localhost" && !ValidateIP(bind) && !ValidateHostname(bind) {
+			return false
+		}
+	}
+	return port != "" && ValidatePort(port)
+}
+
+// ValidateAddressFamily checks an AddressFamily value: any, inet or inet6
+func ValidateAddressFamily(family string) bool {
+	switch strings.ToLower(family) {
+	case "any", "inet", "inet6":
+		return true
+	}
+	return false
+}
+
+// ValidateYesNo checks the value of a yes/no directive such as ForwardX11
+func ValidateYesNo(value string) bool {
+	switch strings.ToLower(value) {
+	case "yes", "no":
+		return true
+	}
+	return false
+}
+
 // ValidateHostName checks if a host name is valid for SSH config
 func ValidateHostName(name string) bool {
 	if len(name) == 0 || len(name) > 50 {
@@ -54,13 +90,14 @@ func ValidateIdentityFile(path string) bool {
 	if path == "" {
 		return true // Optional field
 	}
-	// Expand ~ to home directory
-	if strings.HasPrefix(path, "~/") {
+	path = strings.Trim(path, "\"")
+	// Expand ~ to home directory, accepting both / and \ as separators
+	if strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
 		homeDir, err := os.UserHomeDir()
 		if err != nil {
 			return false
 		}
-		path = filepath.Join(homeDir, path[2:])
+		path = filepath.Join(homeDir, filepath.FromSlash(strings.ReplaceAll(path[2:], `\`, "/")))
 	}
 	_, err := os.Stat(path)
 	return err == nil